@@ -1,6 +1,7 @@
 package database
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"time" // Added time
@@ -19,10 +20,13 @@ type AccountMap struct {
 	LedgerAccount string
 
 	// New Balance Fields
-	CurrentBalance   float64
-	AvailableBalance float64
+	CurrentBalance   Money `gorm:"column:current_balance_minor"`
+	AvailableBalance Money `gorm:"column:available_balance_minor"`
 	Currency         string
 	LastUpdated      time.Time
+
+	// Newest date a provider has fully synced for this account (incremental sync watermark)
+	SyncedThrough time.Time
 }
 
 // Transaction represents a unified financial event
@@ -33,20 +37,130 @@ type Transaction struct {
 
 	Date     string
 	Payee    string
-	Amount   float64
+	RawPayee string // Descriptor as the source sent it; rules match this so rewriting Payee is repeatable
+	Amount   Money  `gorm:"column:amount_minor"`
 	Currency string
 
 	LedgerCategory string
 	Notes          string
-	IsReviewed     bool `gorm:"default:false"`
+	Tags           []string `gorm:"serializer:json"`
+	IsReviewed     bool     `gorm:"default:false"`
+
+	// Machine guess for uncategorized rows; LedgerCategory only changes when the user accepts it
+	SuggestedCategory    string
+	SuggestionConfidence float64 // 0..1
+	SuggestionRationale  string  // Why, when the backend explains itself (LLM)
+	SuggestionSource     string  // Which backend made the suggestion ("bayes", "llm")
+
+	// Voided rows were removed at the source (e.g. deleted in Splitwise).
+	// They stay in the DB for audit but are excluded from exports.
+	IsVoided   bool `gorm:"default:false;index"`
+	VoidReason string
+	// Set when this row was merged into another as a duplicate (it's also voided)
+	DuplicateOf string `gorm:"index"`
+}
+
+// SourcePayee is the payee rules should match against
+func (t Transaction) SourcePayee() string {
+	if t.RawPayee != "" {
+		return t.RawPayee
+	}
+	return t.Payee
+}
+
+func (t *Transaction) ClearSuggestion() {
+	t.SuggestedCategory = ""
+	t.SuggestionConfidence = 0
+	t.SuggestionRationale = ""
+	t.SuggestionSource = ""
 }
 
-// CategoryRule defines an automatic tagging rule
+// TransactionSplit divides a transaction across several categories.
+// Amounts use the transaction's sign convention and must sum to Transaction.Amount;
+// a part with the opposite sign (e.g. a returned item) is fine.
+type TransactionSplit struct {
+	ID            uint   `gorm:"primaryKey"`
+	TransactionID string `gorm:"index"`
+	Category      string
+	Amount        Money `gorm:"column:amount_minor"`
+	Note          string
+}
+
+// CategoryRule defines an automatic tagging rule.
+// Pattern is matched against the payee; every other condition is optional and ignored when empty.
 type CategoryRule struct {
 	ID       uint   `gorm:"primaryKey"`
 	Priority int    `gorm:"default:10"` // Higher number = runs first
-	Pattern  string `gorm:"unique"`     // Regex string (e.g. "(?i)uber")
-	Category string // The target category (e.g. "Expenses:Transport")
+	Pattern  string // Regex string (e.g. "(?i)uber"), empty matches any payee
+	Category string // The target category (e.g. "Expenses:Transport"), empty leaves it unchanged
+
+	// Amount bounds are inclusive and compared against the absolute amount; use Sign for direction
+	MinAmount    *Money `gorm:"column:min_amount_minor"`
+	MaxAmount    *Money `gorm:"column:max_amount_minor"`
+	Sign         string // "debit" (money out), "credit" (money in) or empty
+	AccountID    string // AccountMap.ExternalID
+	Provider     string // Transaction.Provider label (e.g. "splitwise")
+	Currency     string
+	DateFrom     string // YYYY-MM-DD, inclusive
+	DateTo       string // YYYY-MM-DD, inclusive
+	NotesPattern string // Regex matched against Transaction.Notes
+
+	// Actions besides setting Category; a rule needs at least one action
+	PayeeRewrite string   // New payee; $1 or ${name} expand the Pattern's capture groups
+	AppendNotes  string   // Added to Transaction.Notes unless already present
+	Tags         []string `gorm:"serializer:json"`
+	MarkReviewed bool     // Skip the review queue for matched transactions
+
+	// Usage stats, maintained by the rule engine
+	HitCount    int        `gorm:"default:0"` // Transactions this rule has changed
+	LastMatched *time.Time // Last time it changed one, nil if never
+}
+
+// CSVProfile describes how to read one bank's CSV statement export
+type CSVProfile struct {
+	ID                 uint     `gorm:"primaryKey"`
+	Name               string   `gorm:"unique"`
+	IdentifyingColumns []string `gorm:"serializer:json"` // Headers that must all be present
+	MatchExact         bool     // Header row must contain exactly IdentifyingColumns
+	DateColumn         string
+	DescColumn         string
+	AmountColumn       string
+	DateFormat         string // Go layout, e.g. "01/02/2006". Empty = try common formats
+	InvertAmount       bool   // Card exports list charges as positive numbers
+}
+
+// TransferLink pairs the two legs of a money movement between our own accounts
+// (bank<->bank, bank<->credit card payment, bank<->Splitwise settlement)
+type TransferLink struct {
+	ID      uint   `gorm:"primaryKey"`
+	OutID   string `gorm:"index"` // Leg where money left (negative amount)
+	InID    string `gorm:"index"` // Leg where money arrived (positive amount)
+	Kind    string // "transfer", "card_payment", "splitwise_settlement"
+	Status  string `gorm:"index"` // "suggested", "confirmed", "rejected"
+	DayGap  int    // Days between the two legs' dates
+	Created time.Time
+}
+
+// DuplicateCandidate flags two transactions that look like the same purchase
+// imported twice (e.g. CSV import and SimpleFIN sync)
+type DuplicateCandidate struct {
+	ID      uint   `gorm:"primaryKey"`
+	KeepID  string `gorm:"index"` // Suggested survivor
+	DupID   string `gorm:"index"` // Suggested duplicate to void
+	Score   float64
+	Status  string `gorm:"index"` // "flagged", "merged", "dismissed"
+	Created time.Time
+}
+
+// BalanceSnapshot is an account balance as reported by the provider at the end of Date.
+// There's one per account per day; a later sync on the same day overwrites it.
+type BalanceSnapshot struct {
+	ID        uint   `gorm:"primaryKey"`
+	AccountID string `gorm:"uniqueIndex:idx_snapshot_account_date"` // AccountMap.ExternalID
+	Date      string `gorm:"uniqueIndex:idx_snapshot_account_date"` // YYYY-MM-DD
+	Balance   Money  `gorm:"column:balance_minor"`
+	Currency  string
+	UpdatedAt time.Time
 }
 
 // InitDB initializes the database and performs migrations
@@ -61,14 +175,52 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	err = db.AutoMigrate(&AccountMap{}, &Transaction{}, &CategoryRule{})
+	err = db.AutoMigrate(&AccountMap{}, &Transaction{}, &CategoryRule{}, &CSVProfile{}, &TransferLink{}, &DuplicateCandidate{}, &TransactionSplit{}, &BalanceSnapshot{})
 	if err != nil {
 		return nil, err
 	}
 
+	if err := migrateMoneyColumns(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
+// legacyMoneyColumns maps the old float columns to their minor-unit replacements
+var legacyMoneyColumns = []struct {
+	Model interface{}
+	Table string
+	Old   string
+	New   string
+}{
+	{&Transaction{}, "transactions", "amount", "amount_minor"},
+	{&AccountMap{}, "account_maps", "current_balance", "current_balance_minor"},
+	{&AccountMap{}, "account_maps", "available_balance", "available_balance_minor"},
+}
+
+// migrateMoneyColumns copies float amounts from databases created before Money existed
+// into the integer columns, then drops the float columns so they can't drift again.
+func migrateMoneyColumns(db *gorm.DB) error {
+	for _, c := range legacyMoneyColumns {
+		if !db.Migrator().HasColumn(c.Model, c.Old) {
+			continue
+		}
+		fmt.Printf("[INFO] Migrating %s.%s to %s\n", c.Table, c.Old, c.New)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			sql := fmt.Sprintf("UPDATE %s SET %s = CAST(ROUND(COALESCE(%s, 0) * %d) AS INTEGER)", c.Table, c.New, c.Old, moneyFactor)
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+			return tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", c.Table, c.Old)).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Helper to look up account mapping
 func GetLedgerAccountName(db *gorm.DB, externalID, defaultName string) string {
 	var mapping AccountMap
@@ -79,3 +231,15 @@ func GetLedgerAccountName(db *gorm.DB, externalID, defaultName string) string {
 	}
 	return "Assets:FIXME:" + externalID
 }
+
+// RecordBalance stores an account's balance as of date (YYYY-MM-DD), replacing any earlier
+// snapshot for the same day
+func RecordBalance(db *gorm.DB, accountID, date string, balance Money, currency string) error {
+	var snap BalanceSnapshot
+	db.Limit(1).Find(&snap, "account_id = ? AND date = ?", accountID, date)
+	snap.AccountID = accountID
+	snap.Date = date
+	snap.Balance = balance
+	snap.Currency = currency
+	return db.Save(&snap).Error
+}
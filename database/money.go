@@ -0,0 +1,166 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MoneyScale is the number of fractional digits stored for every amount.
+const MoneyScale = 2
+
+const moneyFactor = 100 // 10^MoneyScale
+
+// Money is an exact amount stored in minor units (hundredths of the currency unit).
+// It is persisted as an INTEGER column and marshalled to JSON as a plain decimal number.
+type Money int64
+
+// ParseMoney parses decimal strings such as "-12.34", "$1,024.5" or "(7.00)".
+// Digits beyond MoneyScale are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, ",", "")
+	s = strings.ReplaceAll(s, "$", "")
+
+	neg := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		neg = true
+		s = s[1 : len(s)-1]
+	}
+	if strings.HasPrefix(s, "-") {
+		neg = !neg
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, fmt.Errorf("invalid amount %q", raw)
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" {
+		intPart = "0"
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("invalid amount %q", raw)
+		}
+	}
+
+	// Round on the first dropped digit
+	roundUp := false
+	if len(fracPart) > MoneyScale {
+		roundUp = fracPart[MoneyScale] >= '5'
+		fracPart = fracPart[:MoneyScale]
+	}
+	fracPart += strings.Repeat("0", MoneyScale-len(fracPart))
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %v", raw, err)
+	}
+	frac, _ := strconv.ParseInt(fracPart, 10, 64)
+	if units > (math.MaxInt64-frac-1)/moneyFactor {
+		return 0, fmt.Errorf("amount out of range %q", raw)
+	}
+
+	m := Money(units*moneyFactor + frac)
+	if roundUp {
+		m++
+	}
+	if neg {
+		m = -m
+	}
+	return m, nil
+}
+
+// MoneyFromFloat converts a legacy float amount, rounding to the nearest minor unit.
+func MoneyFromFloat(f float64) Money {
+	return Money(math.Round(f * moneyFactor))
+}
+
+func (m Money) Neg() Money { return -m }
+
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Float64 is only meant for display or scoring, never for arithmetic on stored amounts.
+func (m Money) Float64() float64 {
+	return float64(m) / moneyFactor
+}
+
+// String renders the amount as a plain decimal, e.g. "-12.34".
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/moneyFactor, v%moneyFactor)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and quoted decimal strings.
+// Exponent forms ("1e2", which some encoders emit for floats) go through float parsing.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" || s == "" {
+		*m = 0
+		return nil
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(f, 0) || math.Abs(f) >= math.MaxInt64/moneyFactor {
+			return fmt.Errorf("invalid amount %q", s)
+		}
+		*m = MoneyFromFloat(f)
+		return nil
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	case float64:
+		*m = Money(math.Round(v))
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return err
+		}
+		*m = Money(n)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*m = Money(n)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"12.34", 1234},
+		{"-12.34", -1234},
+		{"+5", 500},
+		{"$1,024.5", 102450},
+		{"(7.00)", -700},
+		{".5", 50},
+		{"0.005", 1},
+		{"-0.005", -1},
+		{"0.004", 0},
+		{" 3.10 ", 310},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if err != nil {
+			t.Errorf("ParseMoney(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, in := range []string{"", "-", "abc", "1.2.3", "1e2", "99999999999999999999"} {
+		if _, err := ParseMoney(in); err == nil {
+			t.Errorf("ParseMoney(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := map[Money]string{0: "0.00", 5: "0.05", -5: "-0.05", 1234: "12.34", -100000: "-1000.00"}
+	for m, want := range tests {
+		if got := m.String(); got != want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(m), got, want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{`12.34`, 1234},
+		{`"12.34"`, 1234},
+		{`-0.1`, -10},
+		{`null`, 0},
+		{`1e2`, 10000},
+		{`1.5E1`, 1500},
+		{`-2.5e-1`, -25},
+		{`"1e2"`, 10000},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.in), &m); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if m != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.in, m, tt.want)
+		}
+	}
+
+	for _, in := range []string{`1e400`, `"1e"`, `1e30`} {
+		var m Money
+		if err := json.Unmarshal([]byte(in), &m); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want error", in)
+		}
+	}
+
+	out, err := json.Marshal(struct{ A Money }{-1234})
+	if err != nil || string(out) != `{"A":-12.34}` {
+		t.Errorf("Marshal = %s, %v", out, err)
+	}
+}
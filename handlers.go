@@ -2,25 +2,43 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
 	"strings"
 
 	"expense_tracker/database"
+	"expense_tracker/services"
+
+	"gorm.io/gorm"
 )
 
 // DTOs for JSON responses
 type TransactionDTO struct {
-	ID             string  `json:"id"`
-	Date           string  `json:"date"`
-	Payee          string  `json:"payee"`
-	Amount         float64 `json:"amount"`
-	Currency       string  `json:"currency"`
-	AccountName    string  `json:"account_name"`
-	LedgerCategory string  `json:"category"`
-	IsReviewed     bool    `json:"is_reviewed"`
-	Note           string  `json:"note"`
+	ID             string         `json:"id"`
+	Date           string         `json:"date"`
+	Payee          string         `json:"payee"`
+	Amount         database.Money `json:"amount"`
+	Currency       string         `json:"currency"`
+	AccountName    string         `json:"account_name"`
+	LedgerCategory string         `json:"category"`
+	IsReviewed     bool           `json:"is_reviewed"`
+	Note           string         `json:"note"`
+	Tags           []string       `json:"tags"`
+	Suggested      string         `json:"suggested_category,omitempty"`
+	Confidence     float64        `json:"suggestion_confidence,omitempty"`
+	Rationale      string         `json:"suggestion_rationale,omitempty"`
+	Source         string         `json:"suggestion_source,omitempty"`
+	IsVoided       bool           `json:"is_voided"`
+	VoidReason     string         `json:"void_reason"`
+	Splits         []SplitDTO     `json:"splits,omitempty"`
+}
+
+type SplitDTO struct {
+	Category string         `json:"category"`
+	Amount   database.Money `json:"amount"`
+	Note     string         `json:"note"`
 }
 
 // GET /api/transactions
@@ -37,6 +55,12 @@ func handleGetTransactions(w http.ResponseWriter, r *http.Request) {
 		acctMap[a.ExternalID] = a.Name
 	}
 
+	splits, err := services.LoadSplits(db)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
 	var dtos []TransactionDTO
 	for _, t := range txs {
 		acctName := acctMap[t.AccountID]
@@ -54,6 +78,14 @@ func handleGetTransactions(w http.ResponseWriter, r *http.Request) {
 			LedgerCategory: t.LedgerCategory,
 			IsReviewed:     t.IsReviewed,
 			Note:           t.Notes,
+			Tags:           t.Tags,
+			Suggested:      t.SuggestedCategory,
+			Confidence:     t.SuggestionConfidence,
+			Rationale:      t.SuggestionRationale,
+			Source:         t.SuggestionSource,
+			IsVoided:       t.IsVoided,
+			VoidReason:     t.VoidReason,
+			Splits:         splitDTOs(splits[t.ID]),
 		})
 	}
 
@@ -61,6 +93,14 @@ func handleGetTransactions(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(dtos)
 }
 
+func splitDTOs(splits []database.TransactionSplit) []SplitDTO {
+	var dtos []SplitDTO
+	for _, s := range splits {
+		dtos = append(dtos, SplitDTO{Category: s.Category, Amount: s.Amount, Note: s.Note})
+	}
+	return dtos
+}
+
 // POST /api/transactions/update
 func handleUpdateTransaction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -69,10 +109,11 @@ func handleUpdateTransaction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var payload struct {
-		ID       string `json:"id"`
-		Payee    string `json:"payee"`
-		Category string `json:"category"`
-		Note     string `json:"note"`
+		ID       string    `json:"id"`
+		Payee    string    `json:"payee"`
+		Category string    `json:"category"`
+		Note     string    `json:"note"`
+		Tags     *[]string `json:"tags"` // Omit to leave tags alone
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		http.Error(w, err.Error(), 400)
@@ -84,22 +125,328 @@ func handleUpdateTransaction(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Transaction not found", 404)
 		return
 	}
+	before := tx
+
+	if payload.Tags != nil {
+		tags, err := services.NormalizeTags(*payload.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		tx.Tags = tags
+	}
 
 	// Update fields
 	if payload.Payee != "" {
 		tx.Payee = payload.Payee
 	}
-	if payload.Category != "" {
+	// Every newly reviewed or corrected row is a new training example
+	learn := !tx.IsReviewed || (payload.Category != "" && payload.Category != tx.LedgerCategory)
+	if payload.Category != "" && payload.Category != tx.LedgerCategory {
+		// Picking a single category replaces any split
+		db.Where("transaction_id = ?", tx.ID).Delete(&database.TransactionSplit{})
 		tx.LedgerCategory = payload.Category
 	}
 	tx.Notes = payload.Note
 	tx.IsReviewed = true
+	tx.ClearSuggestion()
 
 	db.Save(&tx)
 
 	// Regenerate export immediately
 	go exportService.Export()
 
+	classifier.Learn(before, tx)
+	if learn {
+		scheduleRescore()
+	}
+
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// POST /api/transactions/splits {"id": "...", "splits": [{"category": "...", "amount": -12.34, "note": ""}]}
+// An empty splits list removes the split.
+func handleSetSplits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var payload struct {
+		ID     string     `json:"id"`
+		Splits []SplitDTO `json:"splits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	splits := make([]database.TransactionSplit, len(payload.Splits))
+	for i, s := range payload.Splits {
+		splits[i] = database.TransactionSplit{Category: s.Category, Amount: s.Amount, Note: strings.TrimSpace(s.Note)}
+	}
+
+	if err := services.SetSplits(db, payload.ID, splits); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSplit):
+			http.Error(w, err.Error(), 400)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Transaction not found", 404)
+		default:
+			http.Error(w, err.Error(), 500)
+		}
+		return
+	}
+
+	go exportService.Export()
+
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// GET /api/export?format=csv|json&from=YYYY-MM-DD&to=YYYY-MM-DD&account_id=...
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := services.ExportFilter{From: q.Get("from"), To: q.Get("to"), AccountID: q.Get("account_id")}
+	if err := filter.Validate(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	format := q.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "format must be csv or json", 400)
+		return
+	}
+
+	records, err := exportService.Records(filter)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="transactions.`+format+`"`)
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		services.WriteRecordsJSON(w, records)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	services.WriteRecordsCSV(w, records)
+}
+
+type TransferLegDTO struct {
+	ID          string         `json:"id"`
+	Date        string         `json:"date"`
+	Payee       string         `json:"payee"`
+	Amount      database.Money `json:"amount"`
+	AccountName string         `json:"account_name"`
+}
+
+type TransferDTO struct {
+	ID     uint           `json:"id"`
+	Kind   string         `json:"kind"`
+	Status string         `json:"status"`
+	DayGap int            `json:"day_gap"`
+	Out    TransferLegDTO `json:"out"`
+	In     TransferLegDTO `json:"in"`
+}
+
+// GET /api/transfers?status=suggested
+func handleGetTransfers(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = services.TransferSuggested
+	}
+
+	var links []database.TransferLink
+	db.Where("status = ?", status).Order("id desc").Find(&links)
+
+	var accounts []database.AccountMap
+	db.Find(&accounts)
+	acctMap := make(map[string]string)
+	for _, a := range accounts {
+		acctMap[a.ExternalID] = a.Name
+	}
+
+	leg := func(id string) TransferLegDTO {
+		var tx database.Transaction
+		db.Limit(1).Find(&tx, "id = ?", id)
+		return TransferLegDTO{ID: id, Date: tx.Date, Payee: tx.Payee, Amount: tx.Amount, AccountName: acctMap[tx.AccountID]}
+	}
+
+	dtos := []TransferDTO{}
+	for _, l := range links {
+		dtos = append(dtos, TransferDTO{
+			ID:     l.ID,
+			Kind:   l.Kind,
+			Status: l.Status,
+			DayGap: l.DayGap,
+			Out:    leg(l.OutID),
+			In:     leg(l.InID),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dtos)
+}
+
+// POST /api/transfers/match
+func handleMatchTransfers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	count, err := transferMatcher.Run()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Write([]byte(fmt.Sprintf(`{"status":"ok", "suggested": %d}`, count)))
+}
+
+// POST /api/transfers/confirm
+func handleConfirmTransfer(w http.ResponseWriter, r *http.Request) {
+	setTransferStatus(w, r, services.TransferConfirmed)
+}
+
+// POST /api/transfers/reject
+func handleRejectTransfer(w http.ResponseWriter, r *http.Request) {
+	setTransferStatus(w, r, services.TransferRejected)
+}
+
+func setTransferStatus(w http.ResponseWriter, r *http.Request, status string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var payload struct {
+		ID uint `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if err := transferMatcher.SetStatus(payload.ID, status); err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+
+	// Confirming merges the legs; rejecting could matter if it was confirmed before
+	go exportService.Export()
+
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+type DuplicateDTO struct {
+	ID    uint           `json:"id"`
+	Score float64        `json:"score"`
+	Keep  TransactionDTO `json:"keep"`
+	Dup   TransactionDTO `json:"dup"`
+}
+
+// GET /api/duplicates
+func handleGetDuplicates(w http.ResponseWriter, r *http.Request) {
+	var candidates []database.DuplicateCandidate
+	db.Where("status = ?", services.DuplicateFlagged).Order("id desc").Find(&candidates)
+
+	var accounts []database.AccountMap
+	db.Find(&accounts)
+	acctMap := make(map[string]string)
+	for _, a := range accounts {
+		acctMap[a.ExternalID] = a.Name
+	}
+
+	load := func(id string) TransactionDTO {
+		var t database.Transaction
+		db.Limit(1).Find(&t, "id = ?", id)
+		return TransactionDTO{
+			ID:             t.ID,
+			Date:           t.Date,
+			Payee:          t.Payee,
+			Amount:         t.Amount,
+			Currency:       t.Currency,
+			AccountName:    acctMap[t.AccountID],
+			LedgerCategory: t.LedgerCategory,
+			IsReviewed:     t.IsReviewed,
+			Note:           t.Notes,
+		}
+	}
+
+	dtos := []DuplicateDTO{}
+	for _, c := range candidates {
+		dtos = append(dtos, DuplicateDTO{ID: c.ID, Score: c.Score, Keep: load(c.KeepID), Dup: load(c.DupID)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dtos)
+}
+
+// POST /api/duplicates/scan
+func handleScanDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	count, err := duplicateDetector.Run()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Write([]byte(fmt.Sprintf(`{"status":"ok", "flagged": %d}`, count)))
+}
+
+// POST /api/duplicates/merge {"id": 1, "keep_id": "optional tx id to keep instead"}
+func handleMergeDuplicate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var payload struct {
+		ID     uint   `json:"id"`
+		KeepID string `json:"keep_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if err := duplicateDetector.Merge(payload.ID, payload.KeepID); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	go exportService.Export()
+
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// POST /api/duplicates/dismiss
+func handleDismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var payload struct {
+		ID uint `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if err := duplicateDetector.Dismiss(payload.ID); err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
@@ -143,33 +490,222 @@ func handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
-// GET /api/categories
-func handleGetCategories(w http.ResponseWriter, r *http.Request) {
+// listCategories returns every category in use, sorted
+func listCategories() []string {
 	var categories []string
 	db.Model(&database.Transaction{}).Distinct("ledger_category").Pluck("ledger_category", &categories)
 	sort.Strings(categories)
+	return categories
+}
+
+// GET /api/networth?from=YYYY-MM-DD&to=YYYY-MM-DD&interval=day|week|month
+func handleNetWorth(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	report, err := netWorthService.Report(q.Get("from"), q.Get("to"), q.Get("interval"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidNetWorthQuery) {
+			http.Error(w, err.Error(), 400)
+		} else {
+			http.Error(w, err.Error(), 500)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GET /api/categories
+func handleGetCategories(w http.ResponseWriter, r *http.Request) {
+	categories := listCategories()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(categories)
 }
 
-// GET /api/rules
+// GET /api/rules (with hit stats and whether each rule still matches anything)
 func handleGetRules(w http.ResponseWriter, r *http.Request) {
-	var rules []database.CategoryRule
-	db.Order("priority desc").Find(&rules)
+	rules, err := ruleEngine.Report()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(rules)
 }
 
-// POST /api/rules
+// POST /api/rules/add
 func handleCreateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
 	var rule database.CategoryRule
 	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
 		http.Error(w, err.Error(), 400)
 		return
 	}
-	db.Create(&rule)
-	ruleEngine.Reload() // Critical: Update memory!
-	w.Write([]byte(`{"status":"created"}`))
+	if err := ruleEngine.Create(&rule); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Write([]byte(fmt.Sprintf(`{"status":"created", "id": %d}`, rule.ID)))
+}
+
+// POST /api/rules/update (full rule, including ID)
+func handleUpdateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var rule database.CategoryRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := ruleEngine.Update(&rule); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// POST /api/rules/delete
+func handleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var payload struct {
+		ID uint
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := ruleEngine.Delete(payload.ID); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// POST /api/rules/reorder {"ids": [3, 1, 2]} (first runs first)
+func handleReorderRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var payload struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := ruleEngine.Reorder(payload.IDs); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// POST /api/rules/preview (candidate rule; include ID to preview an edit of an existing rule)
+func handlePreviewRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var rule database.CategoryRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	preview, err := ruleEngine.Preview(rule)
+	if err != nil {
+		writeRuleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// GET /api/rules/suggest?id=<transaction id>
+func handleSuggestRule(w http.ResponseWriter, r *http.Request) {
+	suggestion, err := ruleEngine.SuggestRule(r.URL.Query().Get("id"), "")
+	if err != nil {
+		writeSuggestRuleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestion)
+}
+
+// POST /api/rules/from-transaction {"id": "<tx id>", "pattern": "...", "category": "...", "priority": 10}
+// Pattern, category and priority are optional and default to the suggestion.
+func handleCreateRuleFromTransaction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var payload struct {
+		ID       string `json:"id"`
+		Pattern  string `json:"pattern"`
+		Category string `json:"category"`
+		Priority int    `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	suggestion, err := ruleEngine.SuggestRule(payload.ID, payload.Category)
+	if err != nil {
+		writeSuggestRuleError(w, err)
+		return
+	}
+	rule := suggestion.Rule
+	if payload.Pattern != "" {
+		rule.Pattern = payload.Pattern
+	}
+	if payload.Priority != 0 {
+		rule.Priority = payload.Priority
+	}
+
+	if err := ruleEngine.Create(&rule); err != nil {
+		writeRuleError(w, err)
+		return
+	}
+	w.Write([]byte(fmt.Sprintf(`{"status":"created", "id": %d}`, rule.ID)))
+}
+
+// writeSuggestRuleError: a transaction that can't seed a rule is 400, anything else means it wasn't found
+func writeSuggestRuleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, services.ErrRuleInvalid) {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	http.Error(w, err.Error(), 404)
+}
+
+func writeRuleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrRuleNotFound):
+		http.Error(w, err.Error(), 404)
+	case errors.Is(err, services.ErrRuleConflict):
+		http.Error(w, err.Error(), 409)
+	case errors.Is(err, services.ErrRuleInvalid):
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, err.Error(), 500)
+	}
 }
 
 // POST /api/rules/apply
@@ -192,3 +728,152 @@ func handleApplyRules(w http.ResponseWriter, r *http.Request) {
 
 	w.Write([]byte(fmt.Sprintf(`{"status":"ok", "updated": %d}`, count)))
 }
+
+// POST /api/suggestions/refresh
+func handleRefreshSuggestions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	count := refreshSuggestions()
+	w.Write([]byte(fmt.Sprintf(`{"status":"ok", "suggested": %d}`, count)))
+}
+
+// POST /api/suggestions/llm
+// Runs in the background; local models can take minutes for a large backlog
+func handleLLMSuggestions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	if llmCategorizer == nil {
+		http.Error(w, "LLM categorizer not configured (set LLM_MODEL and optionally LLM_BASE_URL)", 400)
+		return
+	}
+	if !llmRunning.TryLock() {
+		http.Error(w, "LLM categorization already running", 409)
+		return
+	}
+
+	go func() {
+		defer llmRunning.Unlock()
+		if _, err := services.RunCategorizer(db, llmCategorizer, listCategories()); err != nil {
+			fmt.Printf("[ERROR] LLM Categorization Failed: %v\n", err)
+		}
+	}()
+	w.Write([]byte(`{"status":"started"}`))
+}
+
+// POST /api/import/csv (multipart: file, account_id, optional account_name, optional profile)
+func handleImportCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", 400)
+		return
+	}
+	defer file.Close()
+
+	accountID := strings.TrimSpace(r.FormValue("account_id"))
+	if accountID == "" {
+		http.Error(w, "account_id is required", 400)
+		return
+	}
+	// Statements for banks we don't sync get a fresh manual account
+	if name := strings.TrimSpace(r.FormValue("account_name")); name != "" {
+		csvImporter.EnsureAccount(accountID, name, "USD")
+	}
+
+	result, err := csvImporter.Import(file, accountID, r.FormValue("profile"))
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if result.Imported > 0 {
+		if _, err := duplicateDetector.Run(); err != nil {
+			fmt.Printf("[WARN] Duplicate Detection Error: %v\n", err)
+			result.Errors = append(result.Errors, "duplicate detection: "+err.Error())
+		}
+		go exportService.Export()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// POST /api/import/ofx (multipart: file). Accounts come from the statement itself.
+func handleImportOFX(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", 400)
+		return
+	}
+	defer file.Close()
+
+	result, err := ofxImporter.Import(file)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if result.Imported > 0 {
+		if _, err := duplicateDetector.Run(); err != nil {
+			fmt.Printf("[WARN] Duplicate Detection Error: %v\n", err)
+			result.Errors = append(result.Errors, "duplicate detection: "+err.Error())
+		}
+	}
+	if result.Imported > 0 || result.Updated > 0 {
+		go exportService.Export()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GET /api/import/profiles
+func handleGetCSVProfiles(w http.ResponseWriter, r *http.Request) {
+	var profiles []database.CSVProfile
+	db.Order("name asc").Find(&profiles)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+// POST /api/import/profiles/add
+func handleCreateCSVProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	var profile database.CSVProfile
+	if err := json.NewDecoder(r.Body).Decode(&profile); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if profile.Name == "" || profile.DateColumn == "" || profile.DescColumn == "" || profile.AmountColumn == "" {
+		http.Error(w, "Name, DateColumn, DescColumn and AmountColumn are required", 400)
+		return
+	}
+	if len(profile.IdentifyingColumns) == 0 {
+		profile.IdentifyingColumns = []string{profile.DateColumn, profile.DescColumn, profile.AmountColumn}
+	}
+
+	profile.ID = 0
+	if err := db.Create(&profile).Error; err != nil {
+		http.Error(w, err.Error(), 409)
+		return
+	}
+	w.Write([]byte(`{"status":"created"}`))
+}
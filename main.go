@@ -5,6 +5,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 
 	"expense_tracker/database"
 	"expense_tracker/services"
@@ -14,10 +16,23 @@ import (
 )
 
 var db *gorm.DB
-var sfService *services.SimpleFinService
-var swService *services.SplitwiseService
+var providers *services.ProviderRegistry
+var csvImporter *services.CSVImportService
+var ofxImporter *services.OFXImportService
 var exportService *services.LedgerExportService
+var exportTables bool // Also write CSV/JSON tables on every sync
+var transferMatcher *services.TransferMatcher
+var duplicateDetector *services.DuplicateDetector
+var netWorthService *services.NetWorthService
 var ruleEngine *services.RuleEngine
+var classifier *services.Classifier
+var llmCategorizer services.Categorizer // nil unless LLM_MODEL is set
+var llmRunning sync.Mutex
+var rescoreMu sync.Mutex
+var rescoreTimer *time.Timer // Pending re-score after reviews, see scheduleRescore
+
+// Reviews tend to come in bursts; re-score pending rows once things go quiet
+const rescoreDelay = 5 * time.Second
 
 func main() {
 	godotenv.Load()
@@ -32,13 +47,35 @@ func main() {
 	// 2. Init Services
 	ruleEngine = services.NewRuleEngine(db)
 	seedDefaultRules(db)
+	seedDefaultCSVProfiles(db)
 	ruleEngine.Reload()
 
-	sfService = services.NewSimpleFinService(db, os.Getenv("SIMPLEFIN_ACCESS_TOKEN"), ruleEngine)
-	swService = services.NewSplitwiseService(db, os.Getenv("SPLITWISE_API_KEY"), ruleEngine)
+	classifier = services.NewClassifier(db)
+	classifier.Train()
+	ruleEngine.Classifier = classifier
+
+	if model := os.Getenv("LLM_MODEL"); model != "" {
+		llmCategorizer = services.NewLLMCategorizer(os.Getenv("LLM_BASE_URL"), model, os.Getenv("LLM_API_KEY"))
+	}
+
+	providers = services.NewProviderRegistry()
+	providers.Register(services.NewSimpleFinService(db, os.Getenv("SIMPLEFIN_ACCESS_TOKEN"), ruleEngine))
+	providers.Register(services.NewSplitwiseService(db, os.Getenv("SPLITWISE_API_KEY"), ruleEngine))
+
+	csvImporter = services.NewCSVImportService(db, ruleEngine)
+	providers.Register(csvImporter)
+
+	ofxImporter = services.NewOFXImportService(db, ruleEngine)
+	providers.Register(ofxImporter)
+
+	transferMatcher = services.NewTransferMatcher(db)
+	duplicateDetector = services.NewDuplicateDetector(db)
+	netWorthService = services.NewNetWorthService(db, providers)
 
 	exportPath := os.Getenv("LEDGER_FILE_PATH")
-	exportService = services.NewLedgerExportService(db, exportPath)
+	exportService = services.NewLedgerExportService(db, exportPath, os.Getenv("EXPORT_FORMAT"), providers)
+	exportService.Git = os.Getenv("EXPORT_GIT") == "true"
+	exportTables = os.Getenv("EXPORT_TABLES") == "true"
 
 	// 3. Run Sync on Startup
 	go runFullSync()
@@ -50,14 +87,38 @@ func main() {
 
 	// API Endpoints (Required for UI to work)
 	http.HandleFunc("/api/sync", handleSync)
+	http.HandleFunc("/api/sync/backfill", handleBackfill)
+	http.HandleFunc("/api/export", handleExport)
+	http.HandleFunc("/api/networth", handleNetWorth)
 	http.HandleFunc("/api/transactions", handleGetTransactions)
 	http.HandleFunc("/api/transactions/update", handleUpdateTransaction)
+	http.HandleFunc("/api/transactions/splits", handleSetSplits)
 	http.HandleFunc("/api/accounts", handleGetAccounts)
 	http.HandleFunc("/api/accounts/update", handleUpdateAccount)
 	http.HandleFunc("/api/categories", handleGetCategories)
 	http.HandleFunc("/api/rules", handleGetRules)       // GET to list
 	http.HandleFunc("/api/rules/add", handleCreateRule) // POST to add
+	http.HandleFunc("/api/rules/update", handleUpdateRule)
+	http.HandleFunc("/api/rules/delete", handleDeleteRule)
+	http.HandleFunc("/api/rules/reorder", handleReorderRules)
+	http.HandleFunc("/api/rules/preview", handlePreviewRule)
+	http.HandleFunc("/api/rules/suggest", handleSuggestRule)
+	http.HandleFunc("/api/rules/from-transaction", handleCreateRuleFromTransaction)
 	http.HandleFunc("/api/rules/apply", handleApplyRules)
+	http.HandleFunc("/api/suggestions/refresh", handleRefreshSuggestions)
+	http.HandleFunc("/api/suggestions/llm", handleLLMSuggestions)
+	http.HandleFunc("/api/transfers", handleGetTransfers)
+	http.HandleFunc("/api/transfers/match", handleMatchTransfers)
+	http.HandleFunc("/api/transfers/confirm", handleConfirmTransfer)
+	http.HandleFunc("/api/transfers/reject", handleRejectTransfer)
+	http.HandleFunc("/api/duplicates", handleGetDuplicates)
+	http.HandleFunc("/api/duplicates/scan", handleScanDuplicates)
+	http.HandleFunc("/api/duplicates/merge", handleMergeDuplicate)
+	http.HandleFunc("/api/duplicates/dismiss", handleDismissDuplicate)
+	http.HandleFunc("/api/import/csv", handleImportCSV)
+	http.HandleFunc("/api/import/ofx", handleImportOFX)
+	http.HandleFunc("/api/import/profiles", handleGetCSVProfiles)
+	http.HandleFunc("/api/import/profiles/add", handleCreateCSVProfile)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -70,12 +131,20 @@ func main() {
 func runFullSync() {
 	fmt.Println("[INFO] Starting Data Sync...")
 
-	if err := sfService.Sync(); err != nil {
-		fmt.Printf("[WARN] SimpleFIN Error: %v\n", err)
+	for _, p := range providers.All() {
+		if err := p.Sync(); err != nil {
+			fmt.Printf("[WARN] %s Error: %v\n", p.Name(), err)
+		}
+	}
+
+	if _, err := duplicateDetector.Run(); err != nil {
+		fmt.Printf("[WARN] Duplicate Detection Error: %v\n", err)
 	}
 
-	if err := swService.Sync(); err != nil {
-		fmt.Printf("[WARN] Splitwise Error: %v\n", err)
+	refreshSuggestions()
+
+	if _, err := transferMatcher.Run(); err != nil {
+		fmt.Printf("[WARN] Transfer Matching Error: %v\n", err)
 	}
 
 	fmt.Println("[INFO] Generating Ledger File...")
@@ -84,6 +153,40 @@ func runFullSync() {
 	} else {
 		fmt.Println("[SUCCESS] Export Complete!")
 	}
+
+	if exportTables {
+		if err := exportService.ExportTables(); err != nil {
+			fmt.Printf("[ERROR] Table Export Failed: %v\n", err)
+		}
+	}
+}
+
+// refreshSuggestions retrains the classifier on the latest reviews and re-scores pending rows
+func refreshSuggestions() int {
+	if err := classifier.Train(); err != nil {
+		fmt.Printf("[WARN] Classifier Training Error: %v\n", err)
+		return 0
+	}
+	count, err := classifier.SuggestPending()
+	if err != nil {
+		fmt.Printf("[WARN] Suggestion Error: %v\n", err)
+	}
+	return count
+}
+
+// scheduleRescore re-scores pending rows rescoreDelay after the last call. The model itself
+// is already up to date (Classifier.Learn); this only refreshes the stored suggestions.
+func scheduleRescore() {
+	rescoreMu.Lock()
+	defer rescoreMu.Unlock()
+	if rescoreTimer != nil {
+		rescoreTimer.Stop()
+	}
+	rescoreTimer = time.AfterFunc(rescoreDelay, func() {
+		if _, err := classifier.SuggestPending(); err != nil {
+			fmt.Printf("[WARN] Suggestion Error: %v\n", err)
+		}
+	})
 }
 
 func handleSync(w http.ResponseWriter, r *http.Request) {
@@ -91,6 +194,42 @@ func handleSync(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"sync_started"}`))
 }
 
+// runBackfill imports history for every provider that supports it, then re-exports
+func runBackfill(from time.Time) {
+	fmt.Printf("[INFO] Starting Backfill from %s...\n", from.Format("2006-01-02"))
+
+	for _, p := range providers.All() {
+		b, ok := p.(services.Backfiller)
+		if !ok {
+			continue
+		}
+		if err := b.Backfill(from); err != nil {
+			fmt.Printf("[WARN] %s Backfill Error: %v\n", p.Name(), err)
+		}
+	}
+
+	if err := exportService.Export(); err != nil {
+		fmt.Printf("[ERROR] Export Failed: %v\n", err)
+	} else {
+		fmt.Println("[SUCCESS] Backfill Complete!")
+	}
+}
+
+// GET /api/sync/backfill?from=YYYY-MM-DD
+func handleBackfill(w http.ResponseWriter, r *http.Request) {
+	from, err := time.Parse("2006-01-02", r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "from must be YYYY-MM-DD", 400)
+		return
+	}
+	if !from.Before(time.Now()) {
+		http.Error(w, "from must be in the past", 400)
+		return
+	}
+	go runBackfill(from)
+	w.Write([]byte(`{"status":"backfill_started"}`))
+}
+
 func seedDefaultRules(db *gorm.DB) {
 	var count int64
 	db.Model(&database.CategoryRule{}).Count(&count)
@@ -106,3 +245,24 @@ func seedDefaultRules(db *gorm.DB) {
 		db.Create(&rules)
 	}
 }
+
+func seedDefaultCSVProfiles(db *gorm.DB) {
+	var count int64
+	db.Model(&database.CSVProfile{}).Count(&count)
+	if count == 0 {
+		fmt.Println("[INFO] Seeding default CSV profiles...")
+		profiles := []database.CSVProfile{
+			{Name: "chase_checking", IdentifyingColumns: []string{"Details", "Posting Date", "Check or Slip #"},
+				DateColumn: "Posting Date", DescColumn: "Description", AmountColumn: "Amount"},
+			{Name: "chase_cc", IdentifyingColumns: []string{"Transaction Date", "Post Date", "Category", "Memo"},
+				DateColumn: "Post Date", DescColumn: "Description", AmountColumn: "Amount", InvertAmount: true},
+			{Name: "sofi", IdentifyingColumns: []string{"Date", "Description", "Type", "Current balance", "Status"},
+				DateColumn: "Date", DescColumn: "Description", AmountColumn: "Amount"},
+			{Name: "amex", IdentifyingColumns: []string{"Date", "Description", "Amount"}, MatchExact: true,
+				DateColumn: "Date", DescColumn: "Description", AmountColumn: "Amount", InvertAmount: true},
+			{Name: "discover", IdentifyingColumns: []string{"Trans. Date", "Post Date", "Category"},
+				DateColumn: "Post Date", DescColumn: "Description", AmountColumn: "Amount", InvertAmount: true},
+		}
+		db.Create(&profiles)
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"expense_tracker/database"
+)
+
+const openingBalanceAccount = "Equity:Opening Balances"
+
+// addBalanceEntries adds a balance assertion for every BalanceSnapshot, so `hledger check`
+// (or beancount) flags missing or duplicated transactions between two syncs.
+//
+// The journal rarely holds an account's full history, so each account also gets an
+// opening balance that makes its first snapshot hold; drift is caught from then on.
+// Assertions are dated the day after the snapshot and sort before that day's transactions,
+// since a snapshot covers everything up to the end of its day.
+func (s *LedgerExportService) addBalanceEntries(buckets map[string][]LedgerEntry) error {
+	var snaps []database.BalanceSnapshot
+	if err := s.DB.Order("date asc").Find(&snaps).Error; err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		return nil
+	}
+
+	var accounts []database.AccountMap
+	if err := s.DB.Find(&accounts).Error; err != nil {
+		return err
+	}
+	// An assertion only makes sense if the ledger account belongs to a single provider account
+	ledgerAccount := make(map[string]string)
+	owners := make(map[string]int)
+	for _, a := range accounts {
+		name := database.GetLedgerAccountName(s.DB, a.ExternalID, "Unknown")
+		ledgerAccount[a.ExternalID] = name
+		owners[name]++
+	}
+
+	type movement struct {
+		date     string
+		currency string
+		amount   database.Money
+	}
+	movements := make(map[string][]movement) // ledger account -> postings
+	for _, entries := range buckets {
+		for _, e := range entries {
+			var total database.Money
+			for _, p := range e.Postings {
+				movements[p.Account] = append(movements[p.Account], movement{e.Date, p.Currency, p.Amount})
+				total += p.Amount
+			}
+			if len(e.Postings) > 0 {
+				movements[e.AccountSource] = append(movements[e.AccountSource], movement{e.Date, e.Postings[0].Currency, total.Neg()})
+			}
+		}
+	}
+
+	byAccount := make(map[string][]database.BalanceSnapshot)
+	for _, snap := range snaps {
+		byAccount[snap.AccountID] = append(byAccount[snap.AccountID], snap)
+	}
+
+	for accountID, history := range byAccount {
+		account, ok := ledgerAccount[accountID]
+		if !ok || owners[account] > 1 {
+			continue
+		}
+
+		first := history[0]
+		var before database.Money
+		openDate := nextDay(first.Date)
+		for _, m := range movements[account] {
+			if m.currency != first.Currency {
+				continue
+			}
+			if m.date <= first.Date {
+				before += m.amount
+			}
+			if m.date < openDate {
+				openDate = m.date
+			}
+		}
+		if opening := first.Balance - before; opening != 0 {
+			addEntry(buckets, LedgerEntry{
+				Date:          previousDay(openDate),
+				Payee:         "Opening balance",
+				Postings:      []LedgerPosting{{Account: account, Amount: opening, Currency: first.Currency}},
+				AccountSource: openingBalanceAccount,
+				Note:          "Derived from the " + first.Date + " balance",
+			})
+		}
+
+		for _, snap := range history {
+			balance := snap.Balance
+			addEntry(buckets, LedgerEntry{
+				Date:      nextDay(snap.Date),
+				Payee:     "Balance assertion",
+				Postings:  []LedgerPosting{{Account: account, Currency: snap.Currency, Assert: &balance}},
+				Note:      "Balance at end of " + snap.Date,
+				Assertion: true,
+			})
+		}
+	}
+
+	// Opening balances and assertions go before the day's transactions
+	for _, entries := range buckets {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Date != entries[j].Date {
+				return entries[i].Date < entries[j].Date
+			}
+			return entries[i].balanceEntry() && !entries[j].balanceEntry()
+		})
+	}
+	return nil
+}
+
+func (e LedgerEntry) balanceEntry() bool {
+	return e.Assertion || e.AccountSource == openingBalanceAccount
+}
+
+func addEntry(buckets map[string][]LedgerEntry, e LedgerEntry) {
+	buckets[e.Date[0:7]] = append(buckets[e.Date[0:7]], e)
+}
+
+func nextDay(date string) string {
+	return shiftDate(date, 1)
+}
+
+func previousDay(date string) string {
+	return shiftDate(date, -1)
+}
+
+func shiftDate(date string, days int) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return t.AddDate(0, 0, days).Format("2006-01-02")
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestBalanceEntries(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]database.AccountMap{
+		{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"},
+		// Two provider accounts feeding one ledger account can't be asserted separately
+		{ExternalID: "card-a", Provider: "simplefin", LedgerAccount: "Liabilities:Card"},
+		{ExternalID: "card-b", Provider: "ofx", LedgerAccount: "Liabilities:Card"},
+	})
+	db.Create(&[]database.Transaction{
+		{ID: "t1", Provider: "simplefin", AccountID: "chk", Date: "2024-03-02", Payee: "RENT", Amount: -1000, Currency: "USD", LedgerCategory: "Expenses:Rent"},
+		{ID: "t2", Provider: "simplefin", AccountID: "chk", Date: "2024-03-10", Payee: "COSTCO", Amount: -500, Currency: "USD", LedgerCategory: "Expenses:Groceries"},
+	})
+	for _, snap := range []struct {
+		account, date string
+		balance       database.Money
+	}{
+		{"chk", "2024-03-05", 9000},
+		{"chk", "2024-03-15", 8500},
+		{"card-a", "2024-03-05", -200},
+	} {
+		if err := database.RecordBalance(db, snap.account, snap.date, snap.balance, "USD"); err != nil {
+			t.Fatalf("RecordBalance: %v", err)
+		}
+	}
+
+	exporter := newTestExporter(t, db, FormatLedger)
+	buckets, err := exporter.entriesByMonth()
+	if err != nil {
+		t.Fatalf("entriesByMonth: %v", err)
+	}
+
+	type balance struct {
+		date    string
+		payee   string
+		account string
+		amount  database.Money
+	}
+	var got []balance
+	for _, e := range buckets["2024-03"] {
+		if !e.balanceEntry() {
+			continue
+		}
+		p := e.Postings[0]
+		b := balance{e.Date, e.Payee, p.Account, p.Amount}
+		if p.Assert != nil {
+			b.amount = *p.Assert
+		}
+		got = append(got, b)
+	}
+	// The opening balance makes the first snapshot hold: 90.00 after the 10.00 rent
+	want := []balance{
+		{"2024-03-01", "Opening balance", "Assets:Checking", 10000},
+		{"2024-03-06", "Balance assertion", "Assets:Checking", 9000},
+		{"2024-03-16", "Balance assertion", "Assets:Checking", 8500},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("balance entries = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("balance entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if err := exporter.Export(); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(exporter.RootDir, "2024", "2024-03.journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Assets:Checking      0.00 USD = 90.00 USD") {
+		t.Errorf("journal has no assertion for the first snapshot:\n%s", data)
+	}
+}
+
+// Without a snapshot there's nothing to assert and no opening balance to derive
+func TestBalanceEntriesWithoutSnapshots(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&database.AccountMap{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"})
+	db.Create(&database.Transaction{ID: "t1", Provider: "simplefin", AccountID: "chk", Date: "2024-03-02", Payee: "RENT", Amount: -1000, Currency: "USD", LedgerCategory: "Expenses:Rent"})
+
+	buckets, err := newTestExporter(t, db, FormatLedger).entriesByMonth()
+	if err != nil {
+		t.Fatalf("entriesByMonth: %v", err)
+	}
+	if entries := buckets["2024-03"]; len(entries) != 1 || entries[0].ID != "t1" {
+		t.Errorf("entries = %+v, want only the transaction", entries)
+	}
+}
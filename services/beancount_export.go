@@ -0,0 +1,227 @@
+package services
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"expense_tracker/database"
+)
+
+// Beancount only allows these five account roots; anything else (e.g. "Transfers:Splitwise") goes under Equity
+var beancountRoots = map[string]bool{"Assets": true, "Liabilities": true, "Equity": true, "Income": true, "Expenses": true}
+
+var (
+	beancountAccountChars = regexp.MustCompile(`[^\p{L}\p{N}-]+`)
+	beancountTagChars     = regexp.MustCompile(`[^A-Za-z0-9_/.-]+`)
+	beancountMetaKeyChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+type beancountOpen struct {
+	Date    string
+	Account string
+}
+
+type beancountEntry struct {
+	Date      string
+	Payee     string // Quoted
+	Narration string // Quoted
+	Tags      string // " #tag #other", or ""
+	Balance   bool   // A balance directive for Postings[0] instead of a transaction
+	Meta      [][2]string
+	Postings  []LedgerPosting
+	Source    string
+}
+
+const beancountMonthTemplate = `
+; Expense Tracker - {{ .Month }}
+; Auto-generated at {{ .GeneratedAt }}
+{{ range .Entries }}
+{{ if .Balance -}}
+{{ .Date }} balance {{ (index .Postings 0).Account }}  {{ (index .Postings 0).Assert }} {{ (index .Postings 0).Currency }}
+{{- else -}}
+{{ .Date }} * {{ .Payee }} {{ .Narration }}{{ .Tags }}
+{{- range .Meta }}
+  {{ index . 0 }}: {{ index . 1 }}
+{{- end }}
+{{- range .Postings }}
+  {{ .Account }}  {{ .Amount }} {{ .Currency }}{{ if .Note }}  ; {{ .Note }}{{ end }}
+{{- end }}
+  {{ .Source }}
+{{- end }}
+{{ end }}`
+
+const beancountAccountsTemplate = `
+; Accounts - auto-generated at {{ .GeneratedAt }}
+{{ range .Opens }}
+{{ .Date }} open {{ .Account }}
+{{- end }}
+`
+
+const beancountMainTemplate = `
+; Main Index File
+; Open this file with: fava main.beancount
+
+option "title" "Expense Tracker"
+
+include "accounts.beancount"
+{{ range .Months }}
+include "{{ slice . 0 4 }}/{{ . }}.beancount"
+{{- end }}
+`
+
+// writeBeancount writes YYYY/YYYY-MM.beancount files, accounts.beancount with an open
+// directive for every account in use, and a main.beancount that includes them all
+func (s *LedgerExportService) writeBeancount(run *exportRun, buckets map[string][]LedgerEntry) error {
+	monthTmpl, err := template.New("beancount").Parse(beancountMonthTemplate)
+	if err != nil {
+		return err
+	}
+	accountsTmpl, err := template.New("accounts").Parse(beancountAccountsTemplate)
+	if err != nil {
+		return err
+	}
+	mainTmpl, err := template.New("main").Parse(beancountMainTemplate)
+	if err != nil {
+		return err
+	}
+
+	generatedAt := time.Now().Format(time.RFC3339)
+	opened := make(map[string]string) // account -> date of first posting
+	use := func(account, date string) string {
+		account = beancountAccount(account)
+		if first, ok := opened[account]; !ok || date < first {
+			opened[account] = date
+		}
+		return account
+	}
+
+	var months []string
+	for monthKey, entries := range buckets {
+		months = append(months, monthKey)
+
+		var out []beancountEntry
+		for _, e := range entries {
+			if e.Assertion {
+				p := e.Postings[0]
+				p.Account = use(p.Account, e.Date)
+				p.Currency = strings.ToUpper(p.Currency)
+				out = append(out, beancountEntry{Date: e.Date, Balance: true, Postings: []LedgerPosting{p}})
+				continue
+			}
+
+			be := beancountEntry{
+				Date:      e.Date,
+				Payee:     beancountString(e.Payee),
+				Narration: beancountString(e.Note),
+				Source:    use(e.AccountSource, e.Date),
+			}
+			if e.ID != "" {
+				be.Meta = append(be.Meta, [2]string{"id", beancountString(e.ID)})
+			}
+			for _, tag := range e.Tags {
+				name, value := TagParts(tag)
+				if value == "" {
+					if t := beancountTagChars.ReplaceAllString(name, "-"); t != "" {
+						be.Tags += " #" + t
+					}
+					continue
+				}
+				if key := beancountMetaKey(name); key != "" && key != "id" {
+					be.Meta = append(be.Meta, [2]string{key, beancountString(value)})
+				}
+			}
+			for _, p := range e.Postings {
+				p.Account = use(p.Account, e.Date)
+				p.Currency = strings.ToUpper(p.Currency)
+				be.Postings = append(be.Postings, p)
+			}
+			out = append(out, be)
+		}
+
+		data := struct {
+			Month       string
+			GeneratedAt string
+			Entries     []beancountEntry
+		}{monthKey, generatedAt, out}
+		if err := run.render(filepath.Join(monthKey[0:4], monthKey+".beancount"), monthTmpl, data); err != nil {
+			return err
+		}
+	}
+	sort.Strings(months)
+
+	// Mapped accounts are opened even before they see a transaction, so balances and Fava's
+	// account list are complete
+	var mapped []database.AccountMap
+	if err := s.DB.Where("ledger_account <> ''").Find(&mapped).Error; err != nil {
+		return err
+	}
+	earliest := "1970-01-01"
+	if len(months) > 0 {
+		earliest = months[0] + "-01"
+	}
+	for _, a := range mapped {
+		use(a.LedgerAccount, earliest)
+	}
+
+	var opens []beancountOpen
+	for account, date := range opened {
+		opens = append(opens, beancountOpen{Date: date, Account: account})
+	}
+	sort.Slice(opens, func(i, j int) bool { return opens[i].Account < opens[j].Account })
+
+	accountsData := struct {
+		GeneratedAt string
+		Opens       []beancountOpen
+	}{generatedAt, opens}
+	if err := run.render("accounts.beancount", accountsTmpl, accountsData); err != nil {
+		return err
+	}
+	return run.render("main.beancount", mainTmpl, struct{ Months []string }{months})
+}
+
+// beancountAccount makes a ledger account name valid for beancount: every component
+// starts with a capital letter or digit and holds only letters, digits and dashes
+// ("Assets:FIXME:ofx_4111" -> "Assets:FIXME:Ofx-4111", "Liabilities:Splitwise:john doe" ->
+// "Liabilities:Splitwise:John-doe")
+func beancountAccount(name string) string {
+	var parts []string
+	for _, part := range strings.Split(name, ":") {
+		part = strings.Trim(beancountAccountChars.ReplaceAllString(part, "-"), "-")
+		if part == "" {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(part)
+		parts = append(parts, string(unicode.ToUpper(r))+part[size:])
+	}
+	if len(parts) == 0 {
+		return "Equity:Unknown"
+	}
+	if !beancountRoots[parts[0]] {
+		parts = append([]string{"Equity"}, parts...)
+	}
+	if len(parts) == 1 {
+		parts = append(parts, "Unknown")
+	}
+	return strings.Join(parts, ":")
+}
+
+// beancountMetaKey lowercases the first letter as beancount requires; "" if nothing usable is left
+func beancountMetaKey(name string) string {
+	key := strings.Trim(beancountMetaKeyChars.ReplaceAllString(name, "-"), "-")
+	r, size := utf8.DecodeRuneInString(key)
+	if !unicode.IsLetter(r) {
+		return ""
+	}
+	return string(unicode.ToLower(r)) + key[size:]
+}
+
+func beancountString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ", "\r", "").Replace(s)
+	return `"` + s + `"`
+}
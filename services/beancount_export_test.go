@@ -0,0 +1,91 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestBeancountAccount(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Expenses:Groceries", "Expenses:Groceries"},
+		{"Assets:FIXME:ofx_4111", "Assets:FIXME:Ofx-4111"},
+		{"Liabilities:Splitwise:john doe", "Liabilities:Splitwise:John-doe"},
+		{"Transfers:Splitwise", "Equity:Transfers:Splitwise"},
+		{"Equity:Opening Balances", "Equity:Opening-Balances"},
+		{"Expenses:Café & Bar", "Expenses:Café-Bar"},
+		{"Assets", "Assets:Unknown"},
+		{"::", "Equity:Unknown"},
+	}
+	for _, tt := range tests {
+		if got := beancountAccount(tt.in); got != tt.want {
+			t.Errorf("beancountAccount(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBeancountMetaKey(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"trip", "trip"},
+		{"Trip Name", "trip-Name"},
+		{"2024", ""},
+		{"!!", ""},
+	}
+	for _, tt := range tests {
+		if got := beancountMetaKey(tt.in); got != tt.want {
+			t.Errorf("beancountMetaKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBeancountExport(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&database.AccountMap{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"})
+	db.Create(&database.Transaction{
+		ID: "t1", Provider: "simplefin", AccountID: "chk", Date: "2024-03-05", Payee: `JOE'S "DINER"`,
+		Amount: -2550, Currency: "usd", LedgerCategory: "Expenses:Dining Out", Notes: "team lunch",
+		Tags: []string{"reimbursable", "trip:japan 2025"},
+	})
+
+	exporter := newTestExporter(t, db, FormatBeancount)
+	if err := exporter.Export(); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	read := func(rel string) string {
+		data, err := os.ReadFile(filepath.Join(exporter.RootDir, rel))
+		if err != nil {
+			t.Fatalf("read %s: %v", rel, err)
+		}
+		return string(data)
+	}
+
+	month := read("2024/2024-03.beancount")
+	for _, want := range []string{
+		`2024-03-05 * "JOE'S \"DINER\"" "team lunch" #reimbursable`,
+		`  id: "t1"`,
+		`  trip: "japan 2025"`,
+		"  Expenses:Dining-Out  25.50 USD",
+		"  Assets:Checking",
+	} {
+		if !strings.Contains(month, want) {
+			t.Errorf("month file is missing %q:\n%s", want, month)
+		}
+	}
+
+	accounts := read("accounts.beancount")
+	for _, want := range []string{"2024-03-01 open Assets:Checking", "2024-03-05 open Expenses:Dining-Out"} {
+		if !strings.Contains(accounts, want) {
+			t.Errorf("accounts.beancount is missing %q:\n%s", want, accounts)
+		}
+	}
+	if main := read("main.beancount"); !strings.Contains(main, `include "2024/2024-03.beancount"`) {
+		t.Errorf("main.beancount doesn't include the month:\n%s", main)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+// Values for Transaction.SuggestionSource
+const (
+	SuggestionBayes = "bayes"
+	SuggestionLLM   = "llm"
+)
+
+// Categorizer is a pluggable backend that suggests categories for a batch of transactions
+type Categorizer interface {
+	// Name is stored as Transaction.SuggestionSource
+	Name() string
+	// Categorize picks one of categories for each transaction it has an opinion on.
+	// Transactions it can't place are left out of the result.
+	Categorize(txs []database.Transaction, categories []string) ([]CategorySuggestion, error)
+}
+
+type CategorySuggestion struct {
+	TxID       string
+	Category   string
+	Confidence float64 // 0..1
+	Rationale  string
+}
+
+// CategorizerBatchSize keeps prompts small enough for local models
+const CategorizerBatchSize = 20
+
+// RunCategorizer suggests categories for every unreviewed, uncategorized transaction.
+// Suggestions outside categories are dropped, and reviewed rows are never written,
+// even if they were reviewed while the backend was thinking.
+func RunCategorizer(db *gorm.DB, c Categorizer, categories []string) (int, error) {
+	categories = slices.DeleteFunc(slices.Clone(categories), func(cat string) bool {
+		return cat == "" || cat == uncategorized
+	})
+	if len(categories) == 0 {
+		return 0, fmt.Errorf("no categories to choose from")
+	}
+
+	var txs []database.Transaction
+	err := db.Where("is_reviewed = ? AND is_voided = ? AND ledger_category = ?", false, false, uncategorized).
+		Order("date desc").Find(&txs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for start := 0; start < len(txs); start += CategorizerBatchSize {
+		batch := txs[start:min(start+CategorizerBatchSize, len(txs))]
+		suggestions, err := c.Categorize(batch, categories)
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", c.Name(), err)
+		}
+
+		inBatch := make(map[string]bool, len(batch))
+		for _, tx := range batch {
+			inBatch[tx.ID] = true
+		}
+		for _, s := range suggestions {
+			if !inBatch[s.TxID] || !slices.Contains(categories, s.Category) {
+				continue
+			}
+			res := db.Model(&database.Transaction{}).
+				Where("id = ? AND is_reviewed = ?", s.TxID, false).
+				Updates(map[string]interface{}{
+					"suggested_category":    s.Category,
+					"suggestion_confidence": max(0, min(1, s.Confidence)),
+					"suggestion_rationale":  s.Rationale,
+					"suggestion_source":     c.Name(),
+				})
+			if res.Error == nil && res.RowsAffected > 0 {
+				count++
+			}
+		}
+	}
+
+	fmt.Printf("[INFO] %s suggested categories for %d of %d transactions\n", c.Name(), count, len(txs))
+	return count, nil
+}
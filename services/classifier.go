@@ -0,0 +1,252 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+const (
+	uncategorized = "Expenses:Uncategorized"
+
+	// Don't guess until there's some history to learn from
+	classifierMinExamples = 10
+	// Suggestions below this posterior probability are noise
+	classifierMinConfidence = 0.4
+)
+
+// Classifier is a naive Bayes model trained on reviewed transactions.
+// It only ever suggests; the category isn't changed until someone accepts it.
+type Classifier struct {
+	DB *gorm.DB
+
+	mu    sync.RWMutex // Guards model, which Learn updates in place
+	model *nbModel
+}
+
+type nbModel struct {
+	docs        int
+	classDocs   map[string]int
+	tokenCounts map[string]map[string]int // category -> token -> count
+	classTokens map[string]int            // category -> total tokens
+	vocab       map[string]int            // token -> count across categories
+}
+
+func newNBModel() *nbModel {
+	return &nbModel{
+		classDocs:   make(map[string]int),
+		tokenCounts: make(map[string]map[string]int),
+		classTokens: make(map[string]int),
+		vocab:       make(map[string]int),
+	}
+}
+
+// add counts tx as an example (delta 1) or takes it back out (delta -1)
+func (m *nbModel) add(tx database.Transaction, delta int) {
+	cat := tx.LedgerCategory
+	m.docs += delta
+	m.classDocs[cat] += delta
+	if m.classDocs[cat] <= 0 {
+		delete(m.classDocs, cat)
+	}
+	if m.tokenCounts[cat] == nil {
+		m.tokenCounts[cat] = make(map[string]int)
+	}
+	for _, tok := range classifierFeatures(tx) {
+		m.tokenCounts[cat][tok] += delta
+		if m.tokenCounts[cat][tok] <= 0 {
+			delete(m.tokenCounts[cat], tok)
+		}
+		m.classTokens[cat] += delta
+		m.vocab[tok] += delta
+		if m.vocab[tok] <= 0 {
+			delete(m.vocab, tok)
+		}
+	}
+	if m.classTokens[cat] <= 0 {
+		delete(m.classTokens, cat)
+		delete(m.tokenCounts, cat)
+	}
+}
+
+// isTrainingExample matches the rows Train learns from
+func isTrainingExample(tx database.Transaction) bool {
+	return tx.IsReviewed && !tx.IsVoided && tx.LedgerCategory != "" && tx.LedgerCategory != uncategorized
+}
+
+func NewClassifier(db *gorm.DB) *Classifier {
+	return &Classifier{DB: db}
+}
+
+// Train rebuilds the model from every reviewed, non-voided, categorized transaction
+func (c *Classifier) Train() error {
+	var txs []database.Transaction
+	err := c.DB.Where("is_reviewed = ? AND is_voided = ? AND ledger_category <> ?", true, false, uncategorized).
+		Find(&txs).Error
+	if err != nil {
+		return err
+	}
+
+	m := newNBModel()
+	for _, tx := range txs {
+		if isTrainingExample(tx) {
+			m.add(tx, 1)
+		}
+	}
+
+	c.mu.Lock()
+	c.model = m
+	c.mu.Unlock()
+	fmt.Printf("[INFO] Trained category classifier on %d reviewed transactions (%d categories)\n", m.docs, len(m.classDocs))
+	return nil
+}
+
+// Learn updates the model for one edited transaction instead of retraining: before is the
+// row as it was (its old example is taken out if it was one) and after the row as saved.
+func (c *Classifier) Learn(before, after database.Transaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.model == nil {
+		return // Not trained yet; Train will pick the row up
+	}
+	if isTrainingExample(before) {
+		c.model.add(before, -1)
+	}
+	if isTrainingExample(after) {
+		c.model.add(after, 1)
+	}
+}
+
+// Suggest returns the most likely category and its posterior probability,
+// or "" when there isn't enough history or the model isn't confident
+func (c *Classifier) Suggest(tx database.Transaction) (string, float64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	m := c.model
+	if m == nil || m.docs < classifierMinExamples || len(m.classDocs) < 2 {
+		return "", 0
+	}
+
+	var known []string
+	for _, tok := range classifierFeatures(tx) {
+		if m.vocab[tok] > 0 {
+			known = append(known, tok)
+		}
+	}
+	// Amount and account alone say little; require at least one payee token we've seen
+	hasPayeeToken := false
+	for _, tok := range known {
+		if tok[0] != '#' {
+			hasPayeeToken = true
+			break
+		}
+	}
+	if !hasPayeeToken {
+		return "", 0
+	}
+
+	// Log-space multinomial NB with Laplace smoothing
+	vocab := float64(len(m.vocab))
+	classes := float64(len(m.classDocs))
+	scores := make(map[string]float64, len(m.classDocs))
+	best, bestScore := "", math.Inf(-1)
+	for cat, n := range m.classDocs {
+		score := math.Log(float64(n+1) / (float64(m.docs) + classes))
+		denom := float64(m.classTokens[cat]) + vocab
+		for _, tok := range known {
+			score += math.Log(float64(m.tokenCounts[cat][tok]+1) / denom)
+		}
+		scores[cat] = score
+		if score > bestScore {
+			best, bestScore = cat, score
+		}
+	}
+
+	// Softmax of the winner against everything else
+	total := 0.0
+	for _, s := range scores {
+		total += math.Exp(s - bestScore)
+	}
+	confidence := 1 / total
+	if confidence < classifierMinConfidence {
+		return "", confidence
+	}
+	return best, confidence
+}
+
+// SuggestPending refreshes suggestions on every unreviewed, uncategorized transaction
+// that doesn't already have an LLM suggestion, and returns how many now have one
+func (c *Classifier) SuggestPending() (int, error) {
+	var txs []database.Transaction
+	err := c.DB.Where("is_reviewed = ? AND is_voided = ? AND ledger_category = ? AND COALESCE(suggestion_source, '') <> ?",
+		false, false, uncategorized, SuggestionLLM).
+		Find(&txs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, tx := range txs {
+		cat, conf := c.Suggest(tx)
+		source := SuggestionBayes
+		if cat == "" {
+			conf, source = 0, ""
+		} else {
+			count++
+		}
+		if cat == tx.SuggestedCategory && conf == tx.SuggestionConfidence && source == tx.SuggestionSource {
+			continue
+		}
+		c.DB.Model(&tx).Updates(map[string]interface{}{
+			"suggested_category":    cat,
+			"suggestion_confidence": conf,
+			"suggestion_rationale":  "",
+			"suggestion_source":     source,
+		})
+	}
+	return count, nil
+}
+
+// classifierFeatures turns a transaction into tokens: payee words, plus "#"-prefixed
+// amount bucket and account so they can't collide with words
+func classifierFeatures(tx database.Transaction) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, word := range strings.Fields(normalizePayee(tx.SourcePayee())) {
+		if len(word) < 2 || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+	}
+	tokens = append(tokens, "#amt:"+amountBucket(tx.Amount), "#acct:"+tx.AccountID)
+	return tokens
+}
+
+// amountBucket groups amounts by direction and rough size
+func amountBucket(m database.Money) string {
+	dir := "out"
+	if m > 0 {
+		dir = "in"
+	}
+	abs := m.Abs().Float64()
+	switch {
+	case abs < 5:
+		return dir + ":<5"
+	case abs < 20:
+		return dir + ":<20"
+	case abs < 50:
+		return dir + ":<50"
+	case abs < 100:
+		return dir + ":<100"
+	case abs < 500:
+		return dir + ":<500"
+	default:
+		return dir + ":500+"
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func seedReviewed(t *testing.T, c *Classifier) {
+	t.Helper()
+	var txs []database.Transaction
+	for i := 0; i < 6; i++ {
+		txs = append(txs,
+			database.Transaction{ID: fmt.Sprintf("coffee%d", i), AccountID: "chk", Payee: "BLUE BOTTLE COFFEE", Amount: -550, LedgerCategory: "Expenses:Coffee", IsReviewed: true},
+			database.Transaction{ID: fmt.Sprintf("fuel%d", i), AccountID: "chk", Payee: "SHELL OIL", Amount: -4000, LedgerCategory: "Expenses:Fuel", IsReviewed: true},
+		)
+	}
+	if err := c.DB.Create(&txs).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClassifierSuggest(t *testing.T) {
+	c := NewClassifier(newTestDB(t))
+	seedReviewed(t, c)
+	if err := c.Train(); err != nil {
+		t.Fatalf("Train: %v", err)
+	}
+
+	cat, conf := c.Suggest(database.Transaction{Payee: "BLUE BOTTLE SF", AccountID: "chk", Amount: -600})
+	if cat != "Expenses:Coffee" || conf < classifierMinConfidence {
+		t.Errorf("Suggest = %q (%.2f), want Expenses:Coffee", cat, conf)
+	}
+	if cat, _ := c.Suggest(database.Transaction{Payee: "UNSEEN MERCHANT", AccountID: "chk", Amount: -600}); cat != "" {
+		t.Errorf("Suggest for an unknown payee = %q, want none", cat)
+	}
+}
+
+// Learn must leave the model exactly where a full retrain would
+func TestClassifierLearnMatchesRetrain(t *testing.T) {
+	c := NewClassifier(newTestDB(t))
+	seedReviewed(t, c)
+	c.Train()
+
+	// Review a new row, then correct an old one
+	var fresh database.Transaction
+	c.DB.Create(&database.Transaction{ID: "new", AccountID: "chk", Payee: "TRADER JOES", Amount: -3000, LedgerCategory: uncategorized})
+	c.DB.First(&fresh, "id = ?", "new")
+	reviewed := fresh
+	reviewed.LedgerCategory, reviewed.IsReviewed = "Expenses:Groceries", true
+	c.DB.Save(&reviewed)
+	c.Learn(fresh, reviewed)
+
+	var old database.Transaction
+	c.DB.First(&old, "id = ?", "fuel0")
+	corrected := old
+	corrected.LedgerCategory = "Expenses:Car"
+	c.DB.Save(&corrected)
+	c.Learn(old, corrected)
+
+	incremental := c.model
+	if err := c.Train(); err != nil {
+		t.Fatalf("Train: %v", err)
+	}
+	retrained := c.model
+
+	if got, want := fmt.Sprint(*incremental), fmt.Sprint(*retrained); got != want {
+		t.Errorf("after Learn:\n%s\nafter Train:\n%s", got, want)
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"crypto/md5"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+// CSVImportService loads bank statement exports into the DB.
+// It's registered as a Provider so exported CSV rows resolve accounts like every other source.
+type CSVImportService struct {
+	DB    *gorm.DB
+	Rules *RuleEngine
+}
+
+func NewCSVImportService(db *gorm.DB, rules *RuleEngine) *CSVImportService {
+	return &CSVImportService{DB: db, Rules: rules}
+}
+
+func (s *CSVImportService) Name() string     { return "csv" }
+func (s *CSVImportService) Labels() []string { return []string{"manual_csv"} }
+
+// Sync is a no-op: CSV data only arrives through Import
+func (s *CSVImportService) Sync() error { return nil }
+
+func (s *CSVImportService) DefaultLedgerAccount(externalID string) string {
+	return "Assets:FIXME:" + externalID
+}
+
+func (s *CSVImportService) EnsureAccount(externalID, name, currency string) {
+	ensureProviderAccount(s.DB, s, externalID, name, currency)
+}
+
+func (s *CSVImportService) ExportHint(tx database.Transaction) ExportHint {
+	return ExportHint{}
+}
+
+// CSVImportResult summarizes a single upload
+type CSVImportResult struct {
+	Profile  string   `json:"profile"`
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"` // Already in the DB
+	Errors   []string `json:"errors"`  // Rows that couldn't be parsed
+}
+
+// Fallback layouts when a profile doesn't set DateFormat
+var csvDateLayouts = []string{
+	"01/02/2006",
+	"1/2/2006",
+	"2006-01-02",
+	"01/02/06",
+	"1/2/06",
+	"01-02-2006",
+	"Jan 2, 2006",
+	"2006/01/02",
+}
+
+// DetectProfile picks the first stored profile whose identifying columns match the header row
+func (s *CSVImportService) DetectProfile(headers []string) (*database.CSVProfile, error) {
+	var profiles []database.CSVProfile
+	if err := s.DB.Order("id asc").Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool)
+	for _, h := range headers {
+		present[strings.TrimSpace(h)] = true
+	}
+
+	for i := range profiles {
+		p := &profiles[i]
+		if len(p.IdentifyingColumns) == 0 {
+			continue
+		}
+		if p.MatchExact && len(headers) != len(p.IdentifyingColumns) {
+			continue
+		}
+		matched := true
+		for _, col := range p.IdentifyingColumns {
+			if !present[col] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown CSV format, headers: %v", headers)
+}
+
+// Import reads a statement into accountID. profileName may be empty to auto-detect.
+func (s *CSVImportService) Import(r io.Reader, accountID, profileName string) (*CSVImportResult, error) {
+	if accountID == "" {
+		return nil, errors.New("account id is required")
+	}
+
+	var account database.AccountMap
+	if err := s.DB.First(&account, "external_id = ?", accountID).Error; err != nil {
+		return nil, fmt.Errorf("account %s not found", accountID)
+	}
+	currency := account.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Banks love trailing commas
+	reader.TrimLeadingSpace = true
+
+	headers, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Strip the UTF-8 BOM Excel adds
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+	}
+
+	var profile *database.CSVProfile
+	if profileName != "" {
+		profile = &database.CSVProfile{}
+		if err := s.DB.First(profile, "name = ?", profileName).Error; err != nil {
+			return nil, fmt.Errorf("profile %s not found", profileName)
+		}
+	} else if profile, err = s.DetectProfile(headers); err != nil {
+		return nil, err
+	}
+
+	colIndex := make(map[string]int)
+	for i, h := range headers {
+		colIndex[strings.TrimSpace(h)] = i
+	}
+	for _, col := range []string{profile.DateColumn, profile.DescColumn, profile.AmountColumn} {
+		if _, ok := colIndex[col]; !ok {
+			return nil, fmt.Errorf("profile %s expects column %q", profile.Name, col)
+		}
+	}
+
+	result := &CSVImportResult{Profile: profile.Name, Errors: []string{}}
+
+	// Identical rows within one file (two coffees on the same day) get distinct IDs
+	seen := make(map[string]int)
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+
+		field := func(col string) string {
+			i := colIndex[col]
+			if i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		rawAmt := field(profile.AmountColumn)
+		if rawAmt == "" {
+			continue
+		}
+		amount, err := database.ParseMoney(rawAmt)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		if profile.InvertAmount {
+			amount = amount.Neg()
+		}
+
+		date, err := parseCSVDate(field(profile.DateColumn), profile.DateFormat)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		desc := field(profile.DescColumn)
+
+		signature := fmt.Sprintf("%s|%s|%s", date, desc, pythonFloatRepr(pythonAmount(rawAmt, profile.InvertAmount, amount)))
+		occurrence := seen[signature]
+		seen[signature] = occurrence + 1
+		txID := csvTransactionID(signature, occurrence)
+
+		var count int64
+		s.DB.Model(&database.Transaction{}).Where("id = ?", txID).Count(&count)
+		if count > 0 {
+			result.Skipped++
+			continue
+		}
+
+		tx := database.Transaction{
+			ID:             txID,
+			Provider:       "manual_csv",
+			AccountID:      accountID,
+			Date:           date,
+			Payee:          desc,
+			RawPayee:       desc,
+			Amount:         amount,
+			Currency:       currency,
+			LedgerCategory: "Expenses:Uncategorized",
+			Notes:          "CSV Import",
+			IsReviewed:     false,
+		}
+		s.Rules.Categorize(&tx)
+		if err := s.DB.Create(&tx).Error; err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		result.Imported++
+	}
+
+	fmt.Printf("[INFO] CSV import (%s): %d new, %d skipped, %d errors\n", profile.Name, result.Imported, result.Skipped, len(result.Errors))
+	return result, nil
+}
+
+func parseCSVDate(raw, layout string) (string, error) {
+	layouts := csvDateLayouts
+	if layout != "" {
+		layouts = []string{layout}
+	}
+	for _, l := range layouts {
+		if t, err := time.Parse(l, raw); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("unrecognized date %q", raw)
+}
+
+// csvTransactionID hashes the row signature plus its occurrence count within the file.
+// The scheme matches the old Python importer so re-importing a statement doesn't duplicate rows.
+func csvTransactionID(signature string, occurrence int) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%s|%d", signature, occurrence)))
+	return fmt.Sprintf("csv_%x", sum)
+}
+
+// pythonAmount is the float the Python importer hashed: float() of the cell without "$" and
+// ",", negated for inverted profiles. It keeps digits beyond the cent and the sign of zero,
+// which Money can't. Cells Python couldn't parse ("(7.00)") fall back to amount.
+func pythonAmount(raw string, invert bool, amount database.Money) float64 {
+	f, err := strconv.ParseFloat(strings.NewReplacer("$", "", ",", "").Replace(raw), 64)
+	if err != nil {
+		return amount.Float64()
+	}
+	if invert {
+		f = -f // amount * -1 in Python, which also turns 0.0 into -0.0
+	}
+	return f
+}
+
+// pythonFloatRepr formats f the way Python's repr(float) does: "-5.0", "12.345", "-0.0",
+// "1e-05", "1e+16". Like Python, it uses the shortest digits that round-trip.
+func pythonFloatRepr(f float64) string {
+	// d.ddde±XX; Python switches to exponent notation outside 1e-4 <= |f| < 1e16
+	sci := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp, _ := strings.Cut(sci, "e")
+	e, _ := strconv.Atoi(exp)
+	if f == 0 || (e >= -4 && e < 16) {
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	sign := "+"
+	if e < 0 {
+		sign, e = "-", -e
+	}
+	return fmt.Sprintf("%se%s%02d", mantissa, sign, e)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestPythonFloatRepr(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{-5, "-5.0"},
+		{12.34, "12.34"},
+		{12.345, "12.345"},
+		{0.30000000000000004, "0.30000000000000004"},
+		{0, "0.0"},
+		{math.Copysign(0, -1), "-0.0"},
+		{0.0001, "0.0001"},
+		{0.00001, "1e-05"},
+		{1.5e-7, "1.5e-07"},
+		{1e15, "1000000000000000.0"},
+		{1e16, "1e+16"},
+		{-1.25e20, "-1.25e+20"},
+	}
+	for _, tt := range tests {
+		if got := pythonFloatRepr(tt.in); got != tt.want {
+			t.Errorf("pythonFloatRepr(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// The IDs below were produced by util/import_csv.py:
+// "csv_" + md5(f"{date_str}|{desc}|{amount}|{occurrence}")
+func TestCSVImportIDsMatchPythonImporter(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&database.AccountMap{ExternalID: "acct", Name: "Card"})
+	db.Create(&database.CSVProfile{Name: "amex", IdentifyingColumns: []string{"Date", "Description", "Amount"}, MatchExact: true,
+		DateColumn: "Date", DescColumn: "Description", AmountColumn: "Amount", InvertAmount: true})
+	importer := NewCSVImportService(db, NewRuleEngine(db))
+
+	statement := strings.Join([]string{
+		"Date,Description,Amount",
+		"01/05/2024,COFFEE SHOP,5.00",
+		"01/05/2024,COFFEE SHOP,5.00",
+		"01/05/2024,COFFEE SHOP,-12.345",
+		"01/05/2024,REFUND,0.00",
+		`01/05/2024,COMMA,"$1,024.50"`,
+	}, "\n")
+	result, err := importer.Import(strings.NewReader(statement), "acct", "")
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.Imported != 5 || len(result.Errors) > 0 {
+		t.Fatalf("Import = %+v, want 5 imported", result)
+	}
+
+	want := map[string]database.Money{
+		"csv_d4be43463b08a9f523074f21c86e11bc": -500,    // -5.0, first
+		"csv_ad3c7c4cd41135a8b529c1cad9cbed3a": -500,    // -5.0, second occurrence
+		"csv_a3b3ed5d47d5999255ffe2acc7bb4b56": 1235,    // 12.345
+		"csv_ee0a13b2a134d60c5151c77be19b0123": 0,       // -0.0
+		"csv_6e4b0d5833e51bd90886e4de909b2517": -102450, // -1024.5
+	}
+	for id, amount := range want {
+		var tx database.Transaction
+		if err := db.First(&tx, "id = ?", id).Error; err != nil {
+			t.Errorf("transaction %s: %v", id, err)
+			continue
+		}
+		if tx.Amount != amount {
+			t.Errorf("transaction %s amount = %s, want %s", id, tx.Amount, amount)
+		}
+	}
+
+	// Re-importing the same statement is a no-op
+	result, err = importer.Import(strings.NewReader(statement), "acct", "")
+	if err != nil {
+		t.Fatalf("second Import: %v", err)
+	}
+	if result.Imported != 0 || result.Skipped != 5 {
+		t.Errorf("second Import = %+v, want all 5 skipped", result)
+	}
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB returns a fresh, fully migrated database that lives for the test only
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "ledger.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	return db
+}
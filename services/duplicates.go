@@ -0,0 +1,304 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DuplicateFlagged   = "flagged"
+	DuplicateMerged    = "merged"
+	DuplicateDismissed = "dismissed"
+
+	// Card posting dates drift a few days between CSV exports and the bank feed
+	duplicateDayWindow = 3
+	// Minimum payee similarity (0..1) before we flag a pair
+	duplicatePayeeThreshold = 0.5
+)
+
+// Sources that keep syncing win over one-off imports when picking which copy to keep
+var duplicateKeepOrder = map[string]int{
+	"simplefin":  3,
+	"ofx":        2,
+	"manual_csv": 1,
+}
+
+// DuplicateDetector flags transactions that were imported twice under different ID schemes
+type DuplicateDetector struct {
+	DB *gorm.DB
+}
+
+func NewDuplicateDetector(db *gorm.DB) *DuplicateDetector {
+	return &DuplicateDetector{DB: db}
+}
+
+// Run flags pairs on the same account with the same amount, dates within duplicateDayWindow
+// and similar payees. Pairs already flagged, merged or dismissed are not flagged again.
+//
+// "Same account" means the same ledger account: an OFX statement and the SimpleFIN feed for
+// one bank account arrive as separate AccountMaps, and mapping both to one ledger account
+// is what says they're the same.
+func (d *DuplicateDetector) Run() (int, error) {
+	var existing []database.DuplicateCandidate
+	if err := d.DB.Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool)
+	for _, c := range existing {
+		seen[pairKey(c.KeepID, c.DupID)] = true
+	}
+
+	var txs []database.Transaction
+	if err := d.DB.Where("is_voided = ?", false).Order("date asc").Find(&txs).Error; err != nil {
+		return 0, err
+	}
+
+	var accounts []database.AccountMap
+	if err := d.DB.Find(&accounts).Error; err != nil {
+		return 0, err
+	}
+	ledgerAccount := make(map[string]string)
+	for _, a := range accounts {
+		if a.LedgerAccount != "" {
+			ledgerAccount[a.ExternalID] = a.LedgerAccount
+		}
+	}
+
+	type groupKey struct {
+		Account string
+		Amount  database.Money
+	}
+	groups := make(map[groupKey][]*database.Transaction)
+	for i := range txs {
+		account, ok := ledgerAccount[txs[i].AccountID]
+		if !ok {
+			account = txs[i].AccountID
+		}
+		k := groupKey{account, txs[i].Amount}
+		groups[k] = append(groups[k], &txs[i])
+	}
+
+	count := 0
+	for _, group := range groups {
+		for i := 0; i < len(group); i++ {
+			a := group[i]
+			aDate, err := time.Parse("2006-01-02", a.Date)
+			if err != nil {
+				continue
+			}
+			for j := i + 1; j < len(group); j++ {
+				b := group[j]
+				// Sorted by date, so everything after this is further away
+				if dayGap(aDate, b.Date) > duplicateDayWindow {
+					break
+				}
+				// Same ID scheme means the same source, which itself considers them distinct
+				// (e.g. two identical coffees on one CSV statement). Provider labels aren't
+				// enough: Splitwise files expenses under three of them.
+				if idScheme(a.ID) == idScheme(b.ID) {
+					continue
+				}
+				if seen[pairKey(a.ID, b.ID)] {
+					continue
+				}
+
+				sim := PayeeSimilarity(a.SourcePayee(), b.SourcePayee())
+				if sim < duplicatePayeeThreshold {
+					continue
+				}
+
+				keep, dup := pickSurvivor(a, b)
+				candidate := database.DuplicateCandidate{
+					KeepID:  keep.ID,
+					DupID:   dup.ID,
+					Score:   sim,
+					Status:  DuplicateFlagged,
+					Created: time.Now(),
+				}
+				if err := d.DB.Create(&candidate).Error; err != nil {
+					return count, err
+				}
+				seen[pairKey(a.ID, b.ID)] = true
+				count++
+			}
+		}
+	}
+
+	if count > 0 {
+		fmt.Printf("[INFO] Flagged %d possible duplicate transactions\n", count)
+	}
+	return count, nil
+}
+
+// Merge voids the duplicate. keepID may swap which side survives.
+// If only the duplicate had been reviewed, its category/payee/notes carry over to the survivor.
+func (d *DuplicateDetector) Merge(id uint, keepID string) error {
+	var c database.DuplicateCandidate
+	if err := d.DB.First(&c, id).Error; err != nil {
+		return err
+	}
+	if c.Status != DuplicateFlagged {
+		return fmt.Errorf("candidate %d is already %s", id, c.Status)
+	}
+	if keepID != "" && keepID != c.KeepID {
+		if keepID != c.DupID {
+			return fmt.Errorf("%s is not part of candidate %d", keepID, id)
+		}
+		c.KeepID, c.DupID = c.DupID, c.KeepID
+	}
+
+	var keep, dup database.Transaction
+	if err := d.DB.First(&keep, "id = ?", c.KeepID).Error; err != nil {
+		return err
+	}
+	if err := d.DB.First(&dup, "id = ?", c.DupID).Error; err != nil {
+		return err
+	}
+
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		if dup.IsReviewed && !keep.IsReviewed {
+			keep.LedgerCategory = dup.LedgerCategory
+			keep.Payee = dup.Payee
+			keep.Notes = dup.Notes
+			keep.IsReviewed = true
+			if err := tx.Save(&keep).Error; err != nil {
+				return err
+			}
+		}
+
+		dup.IsVoided = true
+		dup.VoidReason = "Duplicate of " + keep.ID
+		dup.DuplicateOf = keep.ID
+		dup.IsReviewed = true
+		if err := tx.Save(&dup).Error; err != nil {
+			return err
+		}
+
+		c.Status = DuplicateMerged
+		return tx.Save(&c).Error
+	})
+}
+
+// Dismiss marks a pair as not duplicates so it's never flagged again
+func (d *DuplicateDetector) Dismiss(id uint) error {
+	res := d.DB.Model(&database.DuplicateCandidate{}).
+		Where("id = ? AND status = ?", id, DuplicateFlagged).
+		Update("status", DuplicateDismissed)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("no flagged candidate %d", id)
+	}
+	return nil
+}
+
+// pickSurvivor keeps the reviewed copy, then the one from the longer-lived source, then the older one
+func pickSurvivor(a, b *database.Transaction) (keep, dup *database.Transaction) {
+	if a.IsReviewed != b.IsReviewed {
+		if a.IsReviewed {
+			return a, b
+		}
+		return b, a
+	}
+	if duplicateKeepOrder[a.Provider] != duplicateKeepOrder[b.Provider] {
+		if duplicateKeepOrder[a.Provider] > duplicateKeepOrder[b.Provider] {
+			return a, b
+		}
+		return b, a
+	}
+	return a, b
+}
+
+// idScheme is the prefix that identifies how an ID was generated ("csv", "ofx", "sw", ...)
+func idScheme(id string) string {
+	if i := strings.IndexAny(id, "_-"); i > 0 {
+		return id[:i]
+	}
+	return ""
+}
+
+func pairKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "|" + b
+}
+
+var payeeNoiseRe = regexp.MustCompile(`[^a-z ]+`)
+
+// normalizePayee lowercases and drops digits/punctuation so store numbers and
+// reference codes don't hide a match
+func normalizePayee(p string) string {
+	p = payeeNoiseRe.ReplaceAllString(strings.ToLower(p), " ")
+	return strings.Join(strings.Fields(p), " ")
+}
+
+// PayeeSimilarity scores two payees from 0 (unrelated) to 1 (same after normalization).
+// It takes the better of token overlap and edit distance so both reordered and truncated
+// descriptors ("SQ *BLUE BOTTLE" vs "Blue Bottle Coffee") score well.
+func PayeeSimilarity(a, b string) float64 {
+	na, nb := normalizePayee(a), normalizePayee(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	if na == nb {
+		return 1
+	}
+
+	ta, tb := strings.Fields(na), strings.Fields(nb)
+	setA := make(map[string]bool)
+	for _, t := range ta {
+		setA[t] = true
+	}
+	inter, union := 0, len(setA)
+	seenB := make(map[string]bool)
+	for _, t := range tb {
+		if seenB[t] {
+			continue
+		}
+		seenB[t] = true
+		if setA[t] {
+			inter++
+		} else {
+			union++
+		}
+	}
+	jaccard := float64(inter) / float64(union)
+
+	// Containment catches truncated descriptors ("AMAZON" vs "AMAZON MKTPLACE")
+	if strings.Contains(na, nb) || strings.Contains(nb, na) {
+		jaccard = max(jaccard, 0.8)
+	}
+
+	maxLen := max(len(na), len(nb))
+	edit := 1 - float64(levenshtein(na, nb))/float64(maxLen)
+
+	return max(jaccard, edit)
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
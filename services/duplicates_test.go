@@ -0,0 +1,78 @@
+package services
+
+import (
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestPayeeSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		min  float64 // Inclusive lower bound
+		max  float64 // Inclusive upper bound
+	}{
+		{"AMAZON MKTPLACE PMTS", "amazon mktplace pmts", 1, 1},
+		{"STARBUCKS #1234", "STARBUCKS 5678", 1, 1},
+		{"AMAZON", "AMAZON MKTPLACE", 0.8, 1},
+		{"SQ *BLUE BOTTLE", "Blue Bottle Coffee", duplicatePayeeThreshold, 1},
+		{"SHELL OIL", "WHOLE FOODS", 0, duplicatePayeeThreshold - 0.01},
+		{"", "WHOLE FOODS", 0, 0},
+	}
+	for _, tt := range tests {
+		got := PayeeSimilarity(tt.a, tt.b)
+		if got < tt.min || got > tt.max {
+			t.Errorf("PayeeSimilarity(%q, %q) = %.2f, want %.2f..%.2f", tt.a, tt.b, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestDuplicateDetectorRun(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]database.AccountMap{
+		{ExternalID: "ACT-1", Provider: "simplefin", LedgerAccount: "Liabilities:Chase"},
+		{ExternalID: "ofx_4111", Provider: "ofx", LedgerAccount: "Liabilities:Chase"},
+		{ExternalID: "ofx_9999", Provider: "ofx", LedgerAccount: "Liabilities:Amex"},
+	})
+	db.Create(&[]database.Transaction{
+		// Same card through OFX and the bank feed: a duplicate, the feed copy survives
+		{ID: "TRN-1", Provider: "simplefin", AccountID: "ACT-1", Date: "2024-02-03", Payee: "NETFLIX.COM", Amount: -999},
+		{ID: "ofx_4111_X1", Provider: "ofx", AccountID: "ofx_4111", Date: "2024-02-04", Payee: "NETFLIX", Amount: -999},
+		// Same charge on a different card: not a duplicate
+		{ID: "ofx_9999_X1", Provider: "ofx", AccountID: "ofx_9999", Date: "2024-02-03", Payee: "NETFLIX", Amount: -999},
+		// Two identical coffees on one statement: the source says they're distinct
+		{ID: "csv_a", Provider: "manual_csv", AccountID: "ofx_9999", Date: "2024-02-05", Payee: "COFFEE", Amount: -500},
+		{ID: "csv_b", Provider: "manual_csv", AccountID: "ofx_9999", Date: "2024-02-05", Payee: "COFFEE", Amount: -500},
+		// Two Splitwise dinners filed under different labels are still two expenses
+		{ID: "sw_1", Provider: "splitwise", AccountID: splitwiseAccountID, Date: "2024-02-10", Payee: "DINNER", Amount: -2500},
+		{ID: "sw_2", Provider: "splitwise_payer", AccountID: splitwiseAccountID, Date: "2024-02-12", Payee: "DINNER", Amount: -2500},
+	})
+
+	d := NewDuplicateDetector(db)
+	n, err := d.Run()
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	var found []database.DuplicateCandidate
+	db.Find(&found)
+	if n != 1 || len(found) != 1 {
+		t.Fatalf("flagged %d (%+v), want 1", n, found)
+	}
+	if found[0].KeepID != "TRN-1" || found[0].DupID != "ofx_4111_X1" {
+		t.Errorf("candidate = %+v, want TRN-1 kept over ofx_4111_X1", found[0])
+	}
+
+	// Nothing new on a second run
+	if n, err := d.Run(); err != nil || n != 0 {
+		t.Errorf("second Run = %d, %v; want 0", n, err)
+	}
+
+	if err := d.Merge(found[0].ID, ""); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	var dup database.Transaction
+	db.First(&dup, "id = ?", "ofx_4111_X1")
+	if !dup.IsVoided || dup.DuplicateOf != "TRN-1" {
+		t.Errorf("merged duplicate = %+v, want voided as a duplicate of TRN-1", dup)
+	}
+}
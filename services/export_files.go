@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/template"
+	"time"
+)
+
+var (
+	// The "Auto-generated at" header changes on every run; it doesn't count as a change
+	generatedAtRe = regexp.MustCompile(`(?m)^;.*[Aa]uto-generated at .*$`)
+	yearDirRe     = regexp.MustCompile(`^\d{4}$`)
+	monthFileRe   = regexp.MustCompile(`^\d{4}-\d{2}\.`)
+)
+
+// exportRun writes one export's files. Files whose content hasn't changed are left alone,
+// and everything written goes through a temp file and rename so readers (and crashes)
+// never see a half-written journal.
+type exportRun struct {
+	s        *LedgerExportService
+	produced map[string]bool // Every file this export produced, changed or not
+	Changed  []string        // Rewritten files, relative to RootDir
+	Removed  []string        // Stale month files deleted, relative to RootDir
+}
+
+// writtenFile remembers what we last wrote to a path. The hash is only trusted while the
+// file's size and mtime still match; anything else (a hand edit, a truncated file) gets re-read.
+type writtenFile struct {
+	hash    string
+	size    int64
+	modTime time.Time
+}
+
+func (s *LedgerExportService) newRun() *exportRun {
+	if s.hashes == nil {
+		s.hashes = make(map[string]writtenFile)
+	}
+	return &exportRun{s: s, produced: make(map[string]bool)}
+}
+
+// write stores content at rel (relative to RootDir) unless it's unchanged
+func (r *exportRun) write(rel string, content []byte) error {
+	r.produced[rel] = true
+	path := filepath.Join(r.s.RootDir, rel)
+	hash := contentHash(content)
+
+	info, statErr := os.Stat(path)
+	if statErr == nil {
+		old, ok := r.s.hashes[path]
+		if !ok || old.size != info.Size() || !old.modTime.Equal(info.ModTime()) {
+			// First export since startup, or changed behind our back: compare with the disk
+			old = writtenFile{size: info.Size(), modTime: info.ModTime()}
+			if existing, err := os.ReadFile(path); err == nil {
+				old.hash = contentHash(existing)
+			}
+			r.s.hashes[path] = old
+		}
+		if old.hash == hash {
+			return nil
+		}
+	}
+
+	if err := writeFileAtomic(path, content); err != nil {
+		return err
+	}
+	written := writtenFile{hash: hash}
+	if info, err := os.Stat(path); err == nil {
+		written.size, written.modTime = info.Size(), info.ModTime()
+	}
+	r.s.hashes[path] = written
+	r.Changed = append(r.Changed, rel)
+	return nil
+}
+
+// render executes tmpl and writes the result to rel
+func (r *exportRun) render(rel string, tmpl *template.Template, data interface{}) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	return r.write(rel, buf.Bytes())
+}
+
+// removeStale deletes YYYY/YYYY-MM<ext> files this run didn't produce (months that no
+// longer have transactions), and year directories left empty. Nothing else is touched.
+func (r *exportRun) removeStale(ext string) error {
+	years, err := os.ReadDir(r.s.RootDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, year := range years {
+		if !year.IsDir() || !yearDirRe.MatchString(year.Name()) {
+			continue
+		}
+		yearDir := filepath.Join(r.s.RootDir, year.Name())
+		files, err := os.ReadDir(yearDir)
+		if err != nil {
+			return err
+		}
+		left := len(files)
+		for _, f := range files {
+			rel := filepath.Join(year.Name(), f.Name())
+			if f.IsDir() || filepath.Ext(f.Name()) != ext || !monthFileRe.MatchString(f.Name()) || r.produced[rel] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(yearDir, f.Name())); err != nil {
+				return err
+			}
+			delete(r.s.hashes, filepath.Join(yearDir, f.Name()))
+			r.Removed = append(r.Removed, rel)
+			left--
+		}
+		if left == 0 {
+			os.Remove(yearDir)
+		}
+	}
+	sort.Strings(r.Removed)
+	return nil
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(generatedAtRe.ReplaceAll(content, nil))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"expense_tracker/database"
+)
+
+// exportOnce runs a ledger export the way Export does and returns what it touched
+func exportOnce(t *testing.T, s *LedgerExportService) *exportRun {
+	t.Helper()
+	buckets, err := s.entriesByMonth()
+	if err != nil {
+		t.Fatalf("entriesByMonth: %v", err)
+	}
+	run := s.newRun()
+	if err := s.writeLedger(run, buckets); err != nil {
+		t.Fatalf("writeLedger: %v", err)
+	}
+	if err := run.removeStale(".journal"); err != nil {
+		t.Fatalf("removeStale: %v", err)
+	}
+	return run
+}
+
+func TestExportSkipsUnchangedMonths(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&database.AccountMap{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"})
+	db.Create(&[]database.Transaction{
+		{ID: "t1", Provider: "simplefin", AccountID: "chk", Date: "2024-02-10", Payee: "RENT", Amount: -1000, Currency: "USD", LedgerCategory: "Expenses:Rent"},
+		{ID: "t2", Provider: "simplefin", AccountID: "chk", Date: "2024-03-10", Payee: "COSTCO", Amount: -500, Currency: "USD", LedgerCategory: "Expenses:Groceries"},
+	})
+	exporter := newTestExporter(t, db, FormatLedger)
+
+	run := exportOnce(t, exporter)
+	want := []string{"2024/2024-02.journal", "2024/2024-03.journal", "main.journal"}
+	sort.Strings(run.Changed)
+	if !reflect.DeepEqual(run.Changed, want) {
+		t.Fatalf("first export changed %v, want %v", run.Changed, want)
+	}
+
+	feb := filepath.Join(exporter.RootDir, "2024", "2024-02.journal")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(feb, old, old)
+	exporter.hashes = nil // As after a restart: the files on disk are compared instead
+
+	if run := exportOnce(t, exporter); len(run.Changed) != 0 || len(run.Removed) != 0 {
+		t.Errorf("unchanged export touched %v, removed %v", run.Changed, run.Removed)
+	}
+
+	db.Model(&database.Transaction{}).Where("id = ?", "t2").Update("ledger_category", "Expenses:Household")
+	if run := exportOnce(t, exporter); !reflect.DeepEqual(run.Changed, []string{"2024/2024-03.journal"}) {
+		t.Errorf("recategorizing a March transaction changed %v, want only March", run.Changed)
+	}
+	if info, err := os.Stat(feb); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("February was rewritten")
+	}
+
+	// A hand edit is overwritten on the next export
+	if err := os.WriteFile(feb, []byte("; edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if run := exportOnce(t, exporter); !reflect.DeepEqual(run.Changed, []string{"2024/2024-02.journal"}) {
+		t.Errorf("after a hand edit changed %v, want February", run.Changed)
+	}
+}
+
+func TestExportRemovesStaleMonths(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&database.AccountMap{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"})
+	db.Create(&database.Transaction{ID: "t1", Provider: "simplefin", AccountID: "chk", Date: "2024-03-10", Payee: "COSTCO", Amount: -500, Currency: "USD", LedgerCategory: "Expenses:Groceries"})
+	exporter := newTestExporter(t, db, FormatLedger)
+
+	files := []string{
+		"2022/2022-05.journal",   // Stale, and the only file in its year
+		"2023/2023-01.journal",   // Stale
+		"2023/notes.txt",         // Not ours
+		"2024/2024-01.beancount", // Another format's output
+		"archive/2021-01.journal",
+	}
+	for _, rel := range files {
+		path := filepath.Join(exporter.RootDir, rel)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("; old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := exportOnce(t, exporter)
+	if want := []string{"2022/2022-05.journal", "2023/2023-01.journal"}; !reflect.DeepEqual(run.Removed, want) {
+		t.Errorf("removed %v, want %v", run.Removed, want)
+	}
+	for _, rel := range []string{"2023/notes.txt", "2024/2024-01.beancount", "archive/2021-01.journal", "2024/2024-03.journal"} {
+		if _, err := os.Stat(filepath.Join(exporter.RootDir, rel)); err != nil {
+			t.Errorf("%s should still exist: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(exporter.RootDir, "2022")); !os.IsNotExist(err) {
+		t.Errorf("empty year directory 2022 was kept")
+	}
+}
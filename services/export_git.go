@@ -0,0 +1,242 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// What the last commit contained, so the next message can say what changed.
+// It lives inside .git so it's never committed itself.
+const gitStateFile = "expense-tracker-export.json"
+
+// Longest list of transactions spelled out in a commit message
+const gitMaxListed = 20
+
+// committedEntry is what a commit message needs to know about one exported transaction
+type committedEntry struct {
+	Date     string
+	Payee    string
+	Category string // Posting accounts, comma separated for splits
+}
+
+// exportSnapshot indexes the exported transactions by ID. Balance entries have no ID and
+// aren't tracked.
+func exportSnapshot(buckets map[string][]LedgerEntry) map[string]committedEntry {
+	snapshot := make(map[string]committedEntry)
+	for _, entries := range buckets {
+		for _, e := range entries {
+			if e.ID == "" {
+				continue
+			}
+			var accounts []string
+			for _, p := range e.Postings {
+				accounts = append(accounts, p.Account)
+			}
+			snapshot[e.ID] = committedEntry{Date: e.Date, Payee: e.Payee, Category: strings.Join(accounts, ", ")}
+		}
+	}
+	return snapshot
+}
+
+// commitExport commits RootDir, initializing the repository on first use. snapshot is
+// compared with the previous commit's to describe the change; nil means the run didn't
+// touch the journal (e.g. a table export). Nothing is committed if nothing changed.
+func (s *LedgerExportService) commitExport(run *exportRun, snapshot map[string]committedEntry) error {
+	if err := s.initGitRepo(); err != nil {
+		return err
+	}
+	if _, err := s.git("add", "-A"); err != nil {
+		return err
+	}
+	// Exit status 1 means something is staged
+	if _, err := s.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+
+	statePath := filepath.Join(s.RootDir, ".git", gitStateFile)
+	var previous map[string]committedEntry
+	if snapshot != nil {
+		if data, err := os.ReadFile(statePath); err == nil {
+			if err := json.Unmarshal(data, &previous); err != nil {
+				fmt.Printf("[WARN] Ignoring unreadable %s: %v\n", statePath, err)
+				previous = nil
+			}
+		} else if _, err := s.git("rev-parse", "--verify", "-q", "HEAD"); err != nil {
+			// No commits yet: everything is new
+			previous = map[string]committedEntry{}
+		}
+	}
+
+	msg := gitCommitMessage(run, previous, snapshot)
+	if _, err := s.git("commit", "-q", "-m", msg); err != nil {
+		return err
+	}
+
+	if snapshot != nil {
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(statePath, data)
+	}
+	return nil
+}
+
+// initGitRepo turns RootDir into a git repository unless it already is one. Commits need
+// an identity, so one is set for the repository when git has none configured.
+func (s *LedgerExportService) initGitRepo() error {
+	if _, err := os.Stat(filepath.Join(s.RootDir, ".git")); err != nil {
+		if err := os.MkdirAll(s.RootDir, 0755); err != nil {
+			return err
+		}
+		if _, err := s.git("init", "-q"); err != nil {
+			return err
+		}
+		fmt.Printf("[INFO] Initialized git repository in %s\n", s.RootDir)
+	}
+
+	// Never commit into an enclosing repository (say, a checkout this server runs from)
+	top, err := s.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	if !samePath(top, s.RootDir) {
+		return fmt.Errorf("%s is inside the git repository %s, not a repository of its own", s.RootDir, top)
+	}
+
+	if out, _ := s.git("config", "user.email"); out == "" {
+		if _, err := s.git("config", "user.name", "Expense Tracker"); err != nil {
+			return err
+		}
+		if _, err := s.git("config", "user.email", "expense-tracker@localhost"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// samePath reports whether a and b name the same directory once made absolute and
+// symlinks are resolved
+func samePath(a, b string) bool {
+	resolve := func(p string) string {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			p = real
+		}
+		return filepath.Clean(p)
+	}
+	return resolve(a) == resolve(b)
+}
+
+// git runs a git command in RootDir and returns its trimmed output
+func (s *LedgerExportService) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.RootDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(out) > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitCommitMessage summarizes an export. previous is nil when the last commit's content
+// is unknown, in which case only the touched files are listed.
+func gitCommitMessage(run *exportRun, previous, current map[string]committedEntry) string {
+	var added, recategorized, removed []string
+	if previous != nil && current != nil {
+		for id, e := range current {
+			old, ok := previous[id]
+			switch {
+			case !ok:
+				added = append(added, fmt.Sprintf("%s %s  %s", e.Date, e.Payee, e.Category))
+			case old.Category != e.Category:
+				recategorized = append(recategorized, fmt.Sprintf("%s %s  %s -> %s", e.Date, e.Payee, old.Category, e.Category))
+			}
+		}
+		for id, e := range previous {
+			if _, ok := current[id]; !ok {
+				removed = append(removed, fmt.Sprintf("%s %s  %s", e.Date, e.Payee, e.Category))
+			}
+		}
+	}
+
+	var counts []string
+	for _, c := range []struct {
+		n    int
+		what string
+	}{{len(added), "new"}, {len(recategorized), "recategorized"}, {len(removed), "removed"}} {
+		if c.n > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", c.n, c.what))
+		}
+	}
+
+	months := touchedMonths(run)
+	var subject string
+	switch {
+	case len(counts) > 0:
+		subject = "Export: " + strings.Join(counts, ", ")
+	case len(months) > 0:
+		subject = "Export: update " + strings.Join(months, ", ")
+	case len(run.Changed) > 0:
+		files := append([]string{}, run.Changed...)
+		sort.Strings(files)
+		subject = "Export: update " + strings.Join(files, ", ")
+	default:
+		subject = "Export: update exported files"
+	}
+
+	var b strings.Builder
+	b.WriteString(subject + "\n")
+	if len(months) > 0 {
+		b.WriteString("\nMonths: " + strings.Join(months, ", ") + "\n")
+	}
+	writeList := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		sort.Strings(lines)
+		b.WriteString("\n" + title + ":\n")
+		for i, line := range lines {
+			if i == gitMaxListed {
+				fmt.Fprintf(&b, "  ... and %d more\n", len(lines)-gitMaxListed)
+				break
+			}
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	writeList("New", added)
+	writeList("Recategorized", recategorized)
+	writeList("Removed", removed)
+	return b.String()
+}
+
+// touchedMonths lists the months ("YYYY-MM") whose files a run wrote or deleted
+func touchedMonths(run *exportRun) []string {
+	seen := make(map[string]bool)
+	var months []string
+	for _, rel := range append(append([]string{}, run.Changed...), run.Removed...) {
+		name := filepath.Base(rel)
+		if !monthFileRe.MatchString(name) {
+			continue
+		}
+		month := name[0:7]
+		if !seen[month] {
+			seen[month] = true
+			months = append(months, month)
+		}
+	}
+	sort.Strings(months)
+	return months
+}
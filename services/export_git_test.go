@@ -0,0 +1,85 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+}
+
+func TestGitExportCommits(t *testing.T) {
+	requireGit(t)
+	db := newTestDB(t)
+	db.Create(&database.AccountMap{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"})
+	db.Create(&database.Transaction{ID: "t1", Provider: "simplefin", AccountID: "chk", Date: "2024-03-10", Payee: "COSTCO", Amount: -500, Currency: "USD", LedgerCategory: "Expenses:Uncategorized"})
+	exporter := newTestExporter(t, db, FormatLedger)
+	exporter.Git = true
+
+	lastMessage := func() string {
+		t.Helper()
+		out, err := exporter.git("log", "-1", "--format=%B")
+		if err != nil {
+			t.Fatalf("git log: %v", err)
+		}
+		return out
+	}
+	commits := func() string {
+		out, _ := exporter.git("rev-list", "--count", "HEAD")
+		return out
+	}
+
+	if err := exporter.Export(); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if msg := lastMessage(); !strings.HasPrefix(msg, "Export: 1 new") || !strings.Contains(msg, "2024-03-10 COSTCO  Expenses:Uncategorized") {
+		t.Errorf("first commit message:\n%s", msg)
+	}
+
+	// Nothing changed, nothing committed
+	exporter.Export()
+	if n := commits(); n != "1" {
+		t.Errorf("got %s commits after an unchanged export, want 1", n)
+	}
+
+	db.Model(&database.Transaction{}).Where("id = ?", "t1").Update("ledger_category", "Expenses:Groceries")
+	exporter.Export()
+	msg := lastMessage()
+	if !strings.HasPrefix(msg, "Export: 1 recategorized") || !strings.Contains(msg, "Expenses:Uncategorized -> Expenses:Groceries") || !strings.Contains(msg, "Months: 2024-03") {
+		t.Errorf("recategorize commit message:\n%s", msg)
+	}
+}
+
+func TestGitExportRefusesForeignRepository(t *testing.T) {
+	requireGit(t)
+
+	// An export folder inside another repository gets its own, not a commit in the parent
+	parent := t.TempDir()
+	if out, err := exec.Command("git", "-C", parent, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	s := &LedgerExportService{RootDir: filepath.Join(parent, "books")}
+	if err := s.initGitRepo(); err != nil {
+		t.Fatalf("initGitRepo: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "books", ".git")); err != nil {
+		t.Errorf("no repository of its own: %v", err)
+	}
+
+	// A subdirectory that merely resolves to the parent repository is refused
+	nested := filepath.Join(parent, "nested")
+	os.MkdirAll(filepath.Join(nested, ".git"), 0755)
+	s = &LedgerExportService{RootDir: nested}
+	if err := s.initGitRepo(); err == nil {
+		t.Error("accepted a directory whose repository is its parent")
+	}
+}
@@ -1,9 +1,11 @@
 package services
 
 import (
-	"os"
+	"fmt"
 	"path/filepath"
+	"slices"
 	"sort"
+	"sync"
 	"text/template"
 	"time"
 
@@ -12,27 +14,51 @@ import (
 	"gorm.io/gorm"
 )
 
+// Export formats
+const (
+	FormatLedger    = "ledger"    // ledger-cli / hledger journal files
+	FormatBeancount = "beancount" // Beancount / Fava
+)
+
 type LedgerExportService struct {
-	DB      *gorm.DB
-	RootDir string
+	DB        *gorm.DB
+	RootDir   string
+	Format    string // FormatLedger or FormatBeancount
+	Providers *ProviderRegistry
+	Git       bool // Commit RootDir to a git repository after every export
+
+	mu     sync.Mutex             // Handlers fire exports concurrently; only one runs at a time
+	hashes map[string]writtenFile // Last content written to each file, by path
 }
 
-func NewLedgerExportService(db *gorm.DB, rootDir string) *LedgerExportService {
+func NewLedgerExportService(db *gorm.DB, rootDir, format string, providers *ProviderRegistry) *LedgerExportService {
 	if rootDir == "" {
 		rootDir = "exports"
 	}
-	return &LedgerExportService{DB: db, RootDir: rootDir}
+	if format == "" {
+		format = FormatLedger
+	}
+	return &LedgerExportService{DB: db, RootDir: rootDir, Format: format, Providers: providers}
 }
 
 // Data structure for the template
 type LedgerEntry struct {
+	ID            string // Transaction ID (the outflow leg for transfers)
 	Date          string
 	Payee         string
-	Amount        float64
-	Currency      string
-	AccountDest   string
-	AccountSource string
+	Postings      []LedgerPosting // Destination side; split transactions have several
+	AccountSource string          // Balancing posting, amount left for ledger to infer; empty for assertions
 	Note          string
+	Tags          []string // "reimbursable" or "trip:japan2025"
+	Assertion     bool     // Balance check only: a single zero posting carrying Assert
+}
+
+type LedgerPosting struct {
+	Account  string
+	Amount   database.Money
+	Currency string
+	Note     string
+	Assert   *database.Money // Balance the account must have after this posting
 }
 
 // Template for a single month file
@@ -42,8 +68,15 @@ const monthTemplate = `
 
 {{ range .Entries }}
 {{ .Date }} * {{ .Payee }}
-    {{ .AccountDest }}      {{ printf "%.2f" .Amount }} {{ .Currency }}
+{{- range .Tags }}
+    ; {{ ledgerTag . }}
+{{- end }}
+{{- range .Postings }}
+    {{ .Account }}      {{ .Amount }} {{ .Currency }}{{ if .Assert }} = {{ .Assert }} {{ .Currency }}{{ end }}{{ if .Note }}  ; {{ .Note }}{{ end }}
+{{- end }}
+{{- if .AccountSource }}
     {{ .AccountSource }}
+{{- end }}
     {{ if .Note }}; {{ .Note }}{{ end }}
 {{ end }}
 `
@@ -59,17 +92,71 @@ include {{ . }}/{{ . }}*.journal
 {{ end }}
 `
 
+// Export regenerates the journal. Only months whose content changed are rewritten, and
+// month files that no longer have any transactions are removed.
 func (s *LedgerExportService) Export() error {
-	var transactions []database.Transaction
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buckets, err := s.entriesByMonth()
+	if err != nil {
+		return err
+	}
 
-	// Fetch all transactions
-	if err := s.DB.Order("date asc").Find(&transactions).Error; err != nil {
+	run := s.newRun()
+	var ext string
+	switch s.Format {
+	case FormatLedger:
+		ext, err = ".journal", s.writeLedger(run, buckets)
+	case FormatBeancount:
+		ext, err = ".beancount", s.writeBeancount(run, buckets)
+	default:
+		return fmt.Errorf("unknown export format %q (want %s or %s)", s.Format, FormatLedger, FormatBeancount)
+	}
+	if err != nil {
+		return err
+	}
+	if err := run.removeStale(ext); err != nil {
 		return err
 	}
 
-	// 1. Bucketize by Year-Month (e.g. "2023-10")
+	if len(run.Changed) > 0 || len(run.Removed) > 0 {
+		fmt.Printf("[INFO] Export: %d files updated, %d removed\n", len(run.Changed), len(run.Removed))
+	}
+	if s.Git {
+		// The files are written either way; a failed commit is picked up by the next one
+		if err := s.commitExport(run, exportSnapshot(buckets)); err != nil {
+			fmt.Printf("[WARN] Export git commit failed: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// entriesByMonth turns every live transaction into a journal entry, bucketed by "YYYY-MM"
+func (s *LedgerExportService) entriesByMonth() (map[string][]LedgerEntry, error) {
+	var transactions []database.Transaction
+
+	// Fetch all live transactions
+	if err := s.DB.Where("is_voided = ?", false).Order("date asc").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+
+	// Confirmed transfers are written once, from the outflow leg, as a single balanced entry
+	transfers, err := ConfirmedTransfers(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]database.Transaction, len(transactions))
+	for _, tx := range transactions {
+		byID[tx.ID] = tx
+	}
+	splits, err := LoadSplits(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bucketize by Year-Month (e.g. "2023-10")
 	buckets := make(map[string][]LedgerEntry)
-	years := make(map[string]bool) // Track unique years for the index file
 
 	for _, tx := range transactions {
 		// Determine Year and Month from Date string "YYYY-MM-DD"
@@ -77,36 +164,75 @@ func (s *LedgerExportService) Export() error {
 		if len(tx.Date) < 7 {
 			continue
 		}
-		year := tx.Date[0:4]
 		monthKey := tx.Date[0:7] // "2023-10"
 
-		years[year] = true
-
-		// Ledger Logic (Same as before)
-		sourceAcct := database.GetLedgerAccountName(s.DB, tx.AccountID, "Unknown")
-		if tx.Provider == "splitwise" {
-			sourceAcct = "Liabilities:Payable:Splitwise"
-		} else if tx.Provider == "splitwise_payer" {
-			continue // Skip reimbursement records to avoid duplicates
+		sourceAcct, skip := s.ledgerAccount(tx)
+		if skip {
+			continue
 		}
 
-		amount := tx.Amount * -1 // Flip sign
-
 		entry := LedgerEntry{
+			ID:            tx.ID,
 			Date:          tx.Date,
 			Payee:         tx.Payee,
-			Amount:        amount,
-			Currency:      tx.Currency,
-			AccountDest:   tx.LedgerCategory,
 			AccountSource: sourceAcct,
 			Note:          tx.Notes,
+			Tags:          slices.Clone(tx.Tags),
+		}
+
+		if parts := balancedSplits(tx, splits); len(parts) > 0 {
+			for _, part := range parts {
+				entry.Postings = append(entry.Postings, LedgerPosting{
+					Account:  part.Category,
+					Amount:   part.Amount.Neg(), // Flip sign
+					Currency: tx.Currency,
+					Note:     part.Note,
+				})
+			}
+		} else {
+			entry.Postings = []LedgerPosting{{
+				Account:  tx.LedgerCategory,
+				Amount:   tx.Amount.Neg(), // Flip sign
+				Currency: tx.Currency,
+			}}
+		}
+
+		if link, ok := transfers[tx.ID]; ok {
+			partnerID := link.InID
+			if tx.ID == link.InID {
+				partnerID = link.OutID
+			}
+			// A voided or skipped partner falls back to exporting this leg on its own
+			if partner, found := byID[partnerID]; found {
+				if partnerAcct, partnerSkip := s.ledgerAccount(partner); !partnerSkip {
+					if tx.ID == link.InID {
+						continue
+					}
+					entry.Tags = AddTags(entry.Tags, partner.Tags)
+					entry.Postings = []LedgerPosting{{
+						Account:  partnerAcct,
+						Amount:   partner.Amount,
+						Currency: partner.Currency,
+					}}
+				}
+			}
 		}
 
 		buckets[monthKey] = append(buckets[monthKey], entry)
 	}
 
-	// 2. Write Month Files
-	tmpl, err := template.New("ledger").Parse(monthTemplate)
+	if err := s.addBalanceEntries(buckets); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// writeLedger writes YYYY/YYYY-MM.journal files and a main.journal that includes them
+func (s *LedgerExportService) writeLedger(run *exportRun, buckets map[string][]LedgerEntry) error {
+	years := make(map[string]bool) // Track unique years for the index file
+
+	// Write Month Files
+	tmpl, err := template.New("ledger").Funcs(template.FuncMap{"ledgerTag": ledgerTag}).Parse(monthTemplate)
 	if err != nil {
 		return err
 	}
@@ -114,19 +240,7 @@ func (s *LedgerExportService) Export() error {
 	for monthKey, entries := range buckets {
 		// key: "2023-11" -> Year: "2023"
 		year := monthKey[0:4]
-
-		// Ensure directory exports/2023 exists
-		yearDir := filepath.Join(s.RootDir, year)
-		if err := os.MkdirAll(yearDir, 0755); err != nil {
-			return err
-		}
-
-		// Create file exports/2023/2023-11.journal
-		filePath := filepath.Join(yearDir, monthKey+".journal")
-		f, err := os.Create(filePath)
-		if err != nil {
-			return err
-		}
+		years[year] = true
 
 		data := struct {
 			Month       string
@@ -138,18 +252,33 @@ func (s *LedgerExportService) Export() error {
 			Entries:     entries,
 		}
 
-		if err := tmpl.Execute(f, data); err != nil {
-			f.Close()
+		// exports/2023/2023-11.journal
+		if err := run.render(filepath.Join(year, monthKey+".journal"), tmpl, data); err != nil {
 			return err
 		}
-		f.Close()
 	}
 
-	// 3. Write Main Index File (main.journal)
-	return s.writeIndexFile(years)
+	// Write Main Index File (main.journal)
+	return s.writeIndexFile(run, years)
 }
 
-func (s *LedgerExportService) writeIndexFile(yearsMap map[string]bool) error {
+// ledgerAccount resolves the account a transaction posts against, applying provider hints.
+// skip is true when the provider wants the transaction left out of the journal.
+func (s *LedgerExportService) ledgerAccount(tx database.Transaction) (account string, skip bool) {
+	account = database.GetLedgerAccountName(s.DB, tx.AccountID, "Unknown")
+	if p := s.Providers.ForLabel(tx.Provider); p != nil {
+		hint := p.ExportHint(tx)
+		if hint.Skip {
+			return "", true
+		}
+		if hint.SourceAccount != "" {
+			account = hint.SourceAccount
+		}
+	}
+	return account, false
+}
+
+func (s *LedgerExportService) writeIndexFile(run *exportRun, yearsMap map[string]bool) error {
 	// Sort years
 	var years []string
 	for y := range yearsMap {
@@ -162,11 +291,5 @@ func (s *LedgerExportService) writeIndexFile(yearsMap map[string]bool) error {
 		return err
 	}
 
-	f, err := os.Create(filepath.Join(s.RootDir, "main.journal"))
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	return tmpl.Execute(f, struct{ Years []string }{Years: years})
+	return run.render("main.journal", tmpl, struct{ Years []string }{Years: years})
 }
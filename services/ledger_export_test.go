@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+// newTestExporter returns an exporter writing to a temp dir, with the real providers registered
+func newTestExporter(t *testing.T, db *gorm.DB, format string) *LedgerExportService {
+	t.Helper()
+	rules := NewRuleEngine(db)
+	providers := NewProviderRegistry()
+	providers.Register(NewSimpleFinService(db, "", rules))
+	providers.Register(NewSplitwiseService(db, "", rules))
+	return NewLedgerExportService(db, t.TempDir(), format, providers)
+}
+
+func allEntries(buckets map[string][]LedgerEntry) map[string]LedgerEntry {
+	byID := make(map[string]LedgerEntry)
+	for _, entries := range buckets {
+		for _, e := range entries {
+			byID[e.ID] = e
+		}
+	}
+	return byID
+}
+
+func TestTransferExportsOnce(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]database.AccountMap{
+		{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"},
+		{ExternalID: "sav", Provider: "simplefin", LedgerAccount: "Assets:Savings"},
+	})
+	db.Create(&[]database.Transaction{
+		{ID: "out", Provider: "simplefin", AccountID: "chk", Date: "2024-03-01", Payee: "XFER", Amount: -5000, Currency: "USD", LedgerCategory: "Expenses:Uncategorized"},
+		{ID: "in", Provider: "simplefin", AccountID: "sav", Date: "2024-03-02", Payee: "XFER", Amount: 5000, Currency: "USD", LedgerCategory: "Income:Uncategorized"},
+	})
+	db.Create(&database.TransferLink{OutID: "out", InID: "in", Status: TransferConfirmed})
+
+	buckets, err := newTestExporter(t, db, FormatLedger).entriesByMonth()
+	if err != nil {
+		t.Fatalf("entriesByMonth: %v", err)
+	}
+	entries := allEntries(buckets)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want the transfer once: %+v", len(entries), entries)
+	}
+	e := entries["out"]
+	if e.AccountSource != "Assets:Checking" || len(e.Postings) != 1 || e.Postings[0].Account != "Assets:Savings" || e.Postings[0].Amount != 5000 {
+		t.Errorf("transfer entry = %+v", e)
+	}
+}
+
+// A skipped leg (here a Splitwise reimbursement record) must not take its partner with it
+func TestTransferWithSkippedLegExportsOtherLeg(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]database.AccountMap{
+		{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"},
+		{ExternalID: "splitwise_main", Provider: "splitwise", LedgerAccount: SplitwiseLedgerAccount},
+	})
+	db.Create(&[]database.Transaction{
+		{ID: "out", Provider: "splitwise_payer", AccountID: "splitwise_main", Date: "2024-03-01", Payee: "Dinner", Amount: -2000, Currency: "USD"},
+		{ID: "in", Provider: "simplefin", AccountID: "chk", Date: "2024-03-01", Payee: "VENMO", Amount: 2000, Currency: "USD", LedgerCategory: "Income:Reimbursements"},
+	})
+	db.Create(&database.TransferLink{OutID: "out", InID: "in", Status: TransferConfirmed})
+
+	buckets, err := newTestExporter(t, db, FormatLedger).entriesByMonth()
+	if err != nil {
+		t.Fatalf("entriesByMonth: %v", err)
+	}
+	entries := allEntries(buckets)
+	e, ok := entries["in"]
+	if len(entries) != 1 || !ok {
+		t.Fatalf("entries = %+v, want only the inflow leg", entries)
+	}
+	if e.AccountSource != "Assets:Checking" || e.Postings[0].Account != "Income:Reimbursements" || e.Postings[0].Amount != -2000 {
+		t.Errorf("inflow entry = %+v", e)
+	}
+}
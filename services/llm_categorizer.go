@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"expense_tracker/database"
+)
+
+// LLMCategorizer asks a chat model to categorize transactions. It speaks the OpenAI-compatible
+// chat completions API, which Ollama also serves under /v1.
+type LLMCategorizer struct {
+	BaseURL string // e.g. http://localhost:11434/v1
+	Model   string
+	APIKey  string // Optional; Ollama ignores it
+	Client  *http.Client
+}
+
+func NewLLMCategorizer(baseURL, model, apiKey string) *LLMCategorizer {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434/v1"
+	}
+	return &LLMCategorizer{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Model:   model,
+		APIKey:  apiKey,
+		// Local models can take a while on a full batch
+		Client: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (l *LLMCategorizer) Name() string { return SuggestionLLM }
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model          string            `json:"model"`
+	Messages       []chatMessage     `json:"messages"`
+	Temperature    float64           `json:"temperature"`
+	ResponseFormat map[string]string `json:"response_format,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// llmTransaction is what the model sees of each transaction
+type llmTransaction struct {
+	ID       string         `json:"id"`
+	Date     string         `json:"date"`
+	Payee    string         `json:"payee"`
+	Amount   database.Money `json:"amount"`
+	Currency string         `json:"currency"`
+	Account  string         `json:"account"`
+	Notes    string         `json:"notes,omitempty"`
+}
+
+type llmResult struct {
+	Results []struct {
+		ID         string  `json:"id"`
+		Category   string  `json:"category"`
+		Confidence float64 `json:"confidence"`
+		Rationale  string  `json:"rationale"`
+	} `json:"results"`
+}
+
+const llmSystemPrompt = `You categorize personal finance transactions for a double-entry ledger.
+Negative amounts are money spent, positive amounts are money received.
+Choose each transaction's category from this list only, copied exactly:
+%s
+
+Reply with JSON only, in this shape:
+{"results": [{"id": "<transaction id>", "category": "<category from the list>", "confidence": <0 to 1>, "rationale": "<one short sentence>"}]}
+Leave out any transaction you can't place with reasonable confidence.`
+
+func (l *LLMCategorizer) Categorize(txs []database.Transaction, categories []string) ([]CategorySuggestion, error) {
+	items := make([]llmTransaction, len(txs))
+	for i, tx := range txs {
+		items[i] = llmTransaction{
+			ID:       tx.ID,
+			Date:     tx.Date,
+			Payee:    tx.SourcePayee(),
+			Amount:   tx.Amount,
+			Currency: tx.Currency,
+			Account:  tx.AccountID,
+			Notes:    tx.Notes,
+		}
+	}
+	payload, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(chatRequest{
+		Model: l.Model,
+		Messages: []chatMessage{
+			{Role: "system", Content: fmt.Sprintf(llmSystemPrompt, "- "+strings.Join(categories, "\n- "))},
+			{Role: "user", Content: string(payload)},
+		},
+		Temperature:    0,
+		ResponseFormat: map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, _ := http.NewRequest("POST", l.BaseURL+"/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if l.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+l.APIKey)
+	}
+
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("chat completions error: %d", resp.StatusCode)
+	}
+
+	var chat chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chat); err != nil {
+		return nil, err
+	}
+	if len(chat.Choices) == 0 {
+		return nil, fmt.Errorf("chat completions returned no choices")
+	}
+
+	var result llmResult
+	if err := json.Unmarshal([]byte(stripCodeFence(chat.Choices[0].Message.Content)), &result); err != nil {
+		return nil, fmt.Errorf("model reply isn't the requested JSON: %v", err)
+	}
+
+	suggestions := make([]CategorySuggestion, 0, len(result.Results))
+	for _, r := range result.Results {
+		suggestions = append(suggestions, CategorySuggestion{
+			TxID:       r.ID,
+			Category:   strings.TrimSpace(r.Category),
+			Confidence: r.Confidence,
+			Rationale:  strings.TrimSpace(r.Rationale),
+		})
+	}
+	return suggestions, nil
+}
+
+// stripCodeFence removes the ```json fences some models wrap JSON in despite being asked not to
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimPrefix(s, "json")
+	s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+// stubChatServer is a local OpenAI-compatible endpoint. reply builds the model's message
+// content from the transactions in the request.
+func stubChatServer(t *testing.T, reply func(txs []llmTransaction) string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) != 2 {
+			t.Errorf("bad request: %v %+v", err, req)
+			http.Error(w, "bad request", 400)
+			return
+		}
+		if req.Model != "test-model" || !strings.Contains(req.Messages[0].Content, "- Expenses:Coffee") {
+			t.Errorf("unexpected prompt: %+v", req)
+		}
+		var txs []llmTransaction
+		json.Unmarshal([]byte(req.Messages[1].Content), &txs)
+
+		var resp chatResponse
+		resp.Choices = append(resp.Choices, struct {
+			Message chatMessage `json:"message"`
+		}{chatMessage{Role: "assistant", Content: reply(txs)}})
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestLLMCategorizerCategorize(t *testing.T) {
+	srv := stubChatServer(t, func(txs []llmTransaction) string {
+		return "```json\n" + fmt.Sprintf(`{"results": [{"id": %q, "category": " Expenses:Coffee ", "confidence": 0.9, "rationale": "Coffee shop"}]}`, txs[0].ID) + "\n```"
+	})
+	defer srv.Close()
+
+	llm := NewLLMCategorizer(srv.URL+"/v1/", "test-model", "secret")
+	got, err := llm.Categorize([]database.Transaction{{ID: "t1", Payee: "BLUE BOTTLE", Amount: -550}}, []string{"Expenses:Coffee", "Expenses:Fuel"})
+	if err != nil {
+		t.Fatalf("Categorize: %v", err)
+	}
+	want := CategorySuggestion{TxID: "t1", Category: "Expenses:Coffee", Confidence: 0.9, Rationale: "Coffee shop"}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("Categorize = %+v, want [%+v]", got, want)
+	}
+}
+
+func TestLLMCategorizerErrors(t *testing.T) {
+	srv := stubChatServer(t, func([]llmTransaction) string { return "I think it's coffee" })
+	defer srv.Close()
+
+	llm := NewLLMCategorizer(srv.URL+"/v1", "test-model", "secret")
+	if _, err := llm.Categorize([]database.Transaction{{ID: "t1"}}, []string{"Expenses:Coffee"}); err == nil {
+		t.Error("Categorize accepted a non-JSON reply")
+	}
+	llm.BaseURL = srv.URL + "/missing"
+	if _, err := llm.Categorize([]database.Transaction{{ID: "t1"}}, []string{"Expenses:Coffee"}); err == nil {
+		t.Error("Categorize accepted a 404")
+	}
+}
+
+// End to end against the stub: only valid suggestions on unreviewed rows are stored
+func TestRunCategorizer(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]database.Transaction{
+		{ID: "coffee", Payee: "BLUE BOTTLE", Amount: -550, LedgerCategory: uncategorized},
+		{ID: "mystery", Payee: "ACME", Amount: -100, LedgerCategory: uncategorized},
+		{ID: "reviewed", Payee: "SHELL", Amount: -4000, LedgerCategory: uncategorized, IsReviewed: true},
+	})
+
+	srv := stubChatServer(t, func(txs []llmTransaction) string {
+		var results []string
+		for _, tx := range txs {
+			switch tx.ID {
+			case "coffee":
+				results = append(results, `{"id": "coffee", "category": "Expenses:Coffee", "confidence": 1.5, "rationale": "Coffee shop"}`)
+			case "mystery":
+				results = append(results, `{"id": "mystery", "category": "Expenses:Made Up", "confidence": 0.5, "rationale": "?"}`)
+			}
+		}
+		results = append(results, `{"id": "reviewed", "category": "Expenses:Fuel", "confidence": 0.9, "rationale": "Gas"}`)
+		return `{"results": [` + strings.Join(results, ",") + `]}`
+	})
+	defer srv.Close()
+
+	n, err := RunCategorizer(db, NewLLMCategorizer(srv.URL+"/v1", "test-model", "secret"), []string{"Expenses:Coffee", "Expenses:Fuel", uncategorized})
+	if err != nil {
+		t.Fatalf("RunCategorizer: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("RunCategorizer = %d, want 1", n)
+	}
+
+	var coffee, mystery, reviewed database.Transaction
+	db.First(&coffee, "id = ?", "coffee")
+	db.First(&mystery, "id = ?", "mystery")
+	db.First(&reviewed, "id = ?", "reviewed")
+	if coffee.SuggestedCategory != "Expenses:Coffee" || coffee.SuggestionConfidence != 1 || coffee.SuggestionSource != SuggestionLLM ||
+		coffee.SuggestionRationale != "Coffee shop" || coffee.LedgerCategory != uncategorized {
+		t.Errorf("coffee = %+v, want a clamped LLM suggestion and the category untouched", coffee)
+	}
+	if mystery.SuggestedCategory != "" {
+		t.Errorf("mystery got %q, a category outside the list", mystery.SuggestedCategory)
+	}
+	if reviewed.SuggestedCategory != "" {
+		t.Errorf("reviewed row got suggestion %q", reviewed.SuggestedCategory)
+	}
+}
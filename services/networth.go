@@ -0,0 +1,236 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidNetWorthQuery = errors.New("invalid net worth query")
+
+// NetWorthReport holds balances at the end of each period, column-wise so it charts directly.
+// Balances keep the provider's sign: liabilities are negative.
+type NetWorthReport struct {
+	Interval string           `json:"interval"`
+	Dates    []string         `json:"dates"` // Last day of each period (or the report end)
+	Accounts []NetWorthSeries `json:"accounts"`
+	Totals   []NetWorthTotals `json:"totals"` // One per currency
+}
+
+type NetWorthSeries struct {
+	ID            string            `json:"id"` // AccountMap.ExternalID
+	Name          string            `json:"name"`
+	LedgerAccount string            `json:"ledger_account"`
+	Currency      string            `json:"currency"`
+	Liability     bool              `json:"liability"`
+	Balances      []*database.Money `json:"balances"` // null before the first known balance
+}
+
+type NetWorthTotals struct {
+	Currency    string           `json:"currency"`
+	Assets      []database.Money `json:"assets"`
+	Liabilities []database.Money `json:"liabilities"` // Amount owed, as a positive number
+	NetWorth    []database.Money `json:"net_worth"`   // Assets minus liabilities
+}
+
+type NetWorthService struct {
+	DB        *gorm.DB
+	Providers *ProviderRegistry
+}
+
+func NewNetWorthService(db *gorm.DB, providers *ProviderRegistry) *NetWorthService {
+	return &NetWorthService{DB: db, Providers: providers}
+}
+
+// balancePoint is a known balance from its date onwards
+type balancePoint struct {
+	date    string
+	balance database.Money
+}
+
+// Report builds the net worth time series between from and to (YYYY-MM-DD, both optional)
+// at the given interval ("day", "week" or "month").
+//
+// Bank accounts use their BalanceSnapshot history. Splitwise reports no balance, but we hold
+// its full history, so its payable is the running sum of what the journal posts to it.
+func (s *NetWorthService) Report(from, to, interval string) (*NetWorthReport, error) {
+	if interval == "" {
+		interval = "month"
+	}
+	if interval != "day" && interval != "week" && interval != "month" {
+		return nil, fmt.Errorf("%w: interval must be day, week or month", ErrInvalidNetWorthQuery)
+	}
+	for _, d := range []string{from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return nil, fmt.Errorf("%w: invalid date %q, expected YYYY-MM-DD", ErrInvalidNetWorthQuery, d)
+		}
+	}
+
+	var accounts []database.AccountMap
+	if err := s.DB.Order("name asc").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	history, err := s.balanceHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &NetWorthReport{Interval: interval, Dates: []string{}, Accounts: []NetWorthSeries{}, Totals: []NetWorthTotals{}}
+	if to == "" {
+		to = time.Now().Format("2006-01-02")
+	}
+	if from == "" {
+		for _, points := range history {
+			if len(points) > 0 && (from == "" || points[0].date < from) {
+				from = points[0].date
+			}
+		}
+		if from == "" {
+			return report, nil
+		}
+	}
+	if from > to {
+		return nil, fmt.Errorf("%w: from is after to", ErrInvalidNetWorthQuery)
+	}
+	report.Dates = periodEnds(from, to, interval)
+
+	totals := make(map[string]*NetWorthTotals)
+	for _, acc := range accounts {
+		for _, key := range historyKeys(history, acc.ExternalID) {
+			points := history[key]
+			currency := strings.TrimPrefix(key, acc.ExternalID+"/")
+			ledgerAccount := acc.LedgerAccount
+			if ledgerAccount == "" {
+				ledgerAccount = database.GetLedgerAccountName(s.DB, acc.ExternalID, "Unknown")
+			}
+			series := NetWorthSeries{
+				ID:            acc.ExternalID,
+				Name:          acc.Name,
+				LedgerAccount: ledgerAccount,
+				Currency:      currency,
+				Liability:     strings.HasPrefix(ledgerAccount, "Liabilities"),
+				Balances:      make([]*database.Money, len(report.Dates)),
+			}
+			if len(historyKeys(history, acc.ExternalID)) > 1 {
+				series.Name += " (" + currency + ")"
+			}
+
+			t := totals[currency]
+			if t == nil {
+				n := len(report.Dates)
+				t = &NetWorthTotals{Currency: currency, Assets: make([]database.Money, n), Liabilities: make([]database.Money, n), NetWorth: make([]database.Money, n)}
+				totals[currency] = t
+			}
+
+			next := 0
+			var current *database.Money
+			for i, date := range report.Dates {
+				for next < len(points) && points[next].date <= date {
+					b := points[next].balance
+					current = &b
+					next++
+				}
+				if current == nil {
+					continue
+				}
+				series.Balances[i] = current
+				if series.Liability {
+					t.Liabilities[i] -= *current
+				} else {
+					t.Assets[i] += *current
+				}
+				t.NetWorth[i] += *current
+			}
+			report.Accounts = append(report.Accounts, series)
+		}
+	}
+
+	for _, t := range totals {
+		report.Totals = append(report.Totals, *t)
+	}
+	sort.Slice(report.Totals, func(i, j int) bool { return report.Totals[i].Currency < report.Totals[j].Currency })
+	return report, nil
+}
+
+// balanceHistory returns known balances keyed by "<account id>/<currency>", oldest first
+func (s *NetWorthService) balanceHistory() (map[string][]balancePoint, error) {
+	history := make(map[string][]balancePoint)
+
+	var snaps []database.BalanceSnapshot
+	if err := s.DB.Order("date asc").Find(&snaps).Error; err != nil {
+		return nil, err
+	}
+	for _, snap := range snaps {
+		key := snap.AccountID + "/" + snap.Currency
+		history[key] = append(history[key], balancePoint{snap.Date, snap.Balance})
+	}
+
+	var txs []database.Transaction
+	err := s.DB.Where("account_id = ? AND is_voided = ?", splitwiseAccountID, false).Order("date asc").Find(&txs).Error
+	if err != nil {
+		return nil, err
+	}
+	running := make(map[string]database.Money)
+	for _, tx := range txs {
+		if p := s.Providers.ForLabel(tx.Provider); p != nil && p.ExportHint(tx).Skip {
+			continue
+		}
+		key := splitwiseAccountID + "/" + tx.Currency
+		running[key] += tx.Amount
+		points := history[key]
+		if n := len(points); n > 0 && points[n-1].date == tx.Date {
+			points[n-1].balance = running[key]
+		} else {
+			history[key] = append(points, balancePoint{tx.Date, running[key]})
+		}
+	}
+	return history, nil
+}
+
+// historyKeys lists an account's history keys (one per currency) in a stable order
+func historyKeys(history map[string][]balancePoint, accountID string) []string {
+	var keys []string
+	for key := range history {
+		if strings.HasPrefix(key, accountID+"/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// periodEnds returns the last day of every period overlapping [from, to], with the final
+// period cut off at to. Weeks end on Sunday.
+func periodEnds(from, to, interval string) []string {
+	start, _ := time.Parse("2006-01-02", from)
+	end, _ := time.Parse("2006-01-02", to)
+
+	var dates []string
+	for d := start; !d.After(end); {
+		var periodEnd time.Time
+		switch interval {
+		case "day":
+			periodEnd = d
+		case "week":
+			periodEnd = d.AddDate(0, 0, (7-int(d.Weekday()))%7)
+		default:
+			periodEnd = time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+		}
+		if periodEnd.After(end) {
+			periodEnd = end
+		}
+		dates = append(dates, periodEnd.Format("2006-01-02"))
+		d = periodEnd.AddDate(0, 0, 1)
+	}
+	return dates
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestPeriodEnds(t *testing.T) {
+	tests := []struct {
+		from, to, interval string
+		want               []string
+	}{
+		{"2024-01-15", "2024-03-10", "month", []string{"2024-01-31", "2024-02-29", "2024-03-10"}},
+		{"2023-12-01", "2024-01-31", "month", []string{"2023-12-31", "2024-01-31"}},
+		{"2024-03-06", "2024-03-20", "week", []string{"2024-03-10", "2024-03-17", "2024-03-20"}}, // Wednesday to Wednesday
+		{"2024-03-10", "2024-03-11", "week", []string{"2024-03-10", "2024-03-11"}},               // Starts on a Sunday
+		{"2024-02-28", "2024-03-01", "day", []string{"2024-02-28", "2024-02-29", "2024-03-01"}},
+		{"2024-03-05", "2024-03-05", "month", []string{"2024-03-05"}},
+	}
+	for _, tt := range tests {
+		if got := periodEnds(tt.from, tt.to, tt.interval); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("periodEnds(%s, %s, %s) = %v, want %v", tt.from, tt.to, tt.interval, got, tt.want)
+		}
+	}
+}
+
+// balances renders a series with "-" for periods before the first known balance
+func balances(series []*database.Money) string {
+	var out []string
+	for _, b := range series {
+		if b == nil {
+			out = append(out, "-")
+		} else {
+			out = append(out, b.String())
+		}
+	}
+	return fmt.Sprint(out)
+}
+
+func TestNetWorthReport(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]database.AccountMap{
+		{ExternalID: "chk", Provider: "simplefin", Name: "Checking", LedgerAccount: "Assets:Checking"},
+		{ExternalID: "card", Provider: "simplefin", Name: "Card", LedgerAccount: "Liabilities:Card"},
+		{ExternalID: splitwiseAccountID, Provider: "splitwise", Name: "Splitwise", LedgerAccount: SplitwiseLedgerAccount},
+	})
+	for _, snap := range []struct {
+		account, date string
+		balance       database.Money
+	}{
+		{"chk", "2024-01-10", 100000},
+		{"chk", "2024-03-05", 150000},
+		{"card", "2024-02-01", -30000},
+	} {
+		database.RecordBalance(db, snap.account, snap.date, snap.balance, "USD")
+	}
+	db.Create(&[]database.Transaction{
+		{ID: "sw_1", Provider: "splitwise", AccountID: splitwiseAccountID, Date: "2024-01-20", Amount: -2500, Currency: "USD"},
+		// What others owe us for a dinner we paid is a reimbursement record, not a payable
+		{ID: "sw_2", Provider: "splitwise_payer", AccountID: splitwiseAccountID, Date: "2024-01-25", Amount: -5000, Currency: "USD"},
+		{ID: "sw_3", Provider: "splitwise_payment", AccountID: splitwiseAccountID, Date: "2024-02-15", Amount: 1000, Currency: "USD"},
+	})
+
+	providers := NewProviderRegistry()
+	providers.Register(NewSplitwiseService(db, "", NewRuleEngine(db)))
+	report, err := NewNetWorthService(db, providers).Report("2024-01-01", "2024-03-31", "month")
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if want := []string{"2024-01-31", "2024-02-29", "2024-03-31"}; !reflect.DeepEqual(report.Dates, want) {
+		t.Fatalf("Dates = %v, want %v", report.Dates, want)
+	}
+
+	// Balances carry forward between snapshots; the Splitwise payable stays negative
+	want := map[string]struct {
+		balances  string
+		liability bool
+	}{
+		"chk":              {"[1000.00 1000.00 1500.00]", false},
+		"card":             {"[- -300.00 -300.00]", true},
+		splitwiseAccountID: {"[-25.00 -15.00 -15.00]", true},
+	}
+	if len(report.Accounts) != len(want) {
+		t.Fatalf("got %d series, want %d", len(report.Accounts), len(want))
+	}
+	for _, series := range report.Accounts {
+		w := want[series.ID]
+		if got := balances(series.Balances); got != w.balances || series.Liability != w.liability {
+			t.Errorf("%s: balances %s, liability %v; want %s, %v", series.ID, got, series.Liability, w.balances, w.liability)
+		}
+	}
+
+	if len(report.Totals) != 1 {
+		t.Fatalf("got %d currencies, want 1", len(report.Totals))
+	}
+	totals := report.Totals[0]
+	if fmt.Sprint(totals.Assets) != "[1000.00 1000.00 1500.00]" ||
+		fmt.Sprint(totals.Liabilities) != "[25.00 315.00 315.00]" ||
+		fmt.Sprint(totals.NetWorth) != "[975.00 685.00 1185.00]" {
+		t.Errorf("totals = assets %v, liabilities %v, net worth %v", totals.Assets, totals.Liabilities, totals.NetWorth)
+	}
+}
+
+func TestNetWorthReportValidation(t *testing.T) {
+	s := NewNetWorthService(newTestDB(t), NewProviderRegistry())
+	for _, q := range [][3]string{
+		{"", "", "year"},
+		{"2024-13-01", "", "month"},
+		{"2024-03-01", "2024-02-01", "month"},
+	} {
+		if _, err := s.Report(q[0], q[1], q[2]); !errors.Is(err, ErrInvalidNetWorthQuery) {
+			t.Errorf("Report(%q, %q, %q) err = %v, want ErrInvalidNetWorthQuery", q[0], q[1], q[2], err)
+		}
+	}
+}
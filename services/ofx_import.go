@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+// OFXImportService loads OFX/QFX statement downloads (both 1.x SGML and 2.x XML)
+type OFXImportService struct {
+	DB    *gorm.DB
+	Rules *RuleEngine
+}
+
+func NewOFXImportService(db *gorm.DB, rules *RuleEngine) *OFXImportService {
+	return &OFXImportService{DB: db, Rules: rules}
+}
+
+func (s *OFXImportService) Name() string     { return "ofx" }
+func (s *OFXImportService) Labels() []string { return []string{"ofx"} }
+
+// Sync is a no-op: OFX data only arrives through Import
+func (s *OFXImportService) Sync() error { return nil }
+
+func (s *OFXImportService) DefaultLedgerAccount(externalID string) string {
+	return "Assets:FIXME:" + externalID
+}
+
+func (s *OFXImportService) EnsureAccount(externalID, name, currency string) {
+	ensureProviderAccount(s.DB, s, externalID, name, currency)
+}
+
+func (s *OFXImportService) ExportHint(tx database.Transaction) ExportHint {
+	return ExportHint{}
+}
+
+// OFXStatement is one <STMTRS> or <CCSTMTRS> block
+type OFXStatement struct {
+	AccountID    string
+	BankID       string
+	AccountType  string
+	Currency     string
+	Balance      database.Money // LEDGERBAL
+	BalanceDate  string
+	Available    *database.Money // AVAILBAL, when the bank sends it
+	Transactions []OFXTransaction
+}
+
+type OFXTransaction struct {
+	FITID  string
+	Posted string // YYYY-MM-DD
+	Amount database.Money
+	Name   string
+	Memo   string
+}
+
+type OFXImportResult struct {
+	Accounts []string `json:"accounts"`
+	Imported int      `json:"imported"`
+	Updated  int      `json:"updated"`
+	Errors   []string `json:"errors"`
+}
+
+// ofxTagRe matches an opening/closing tag and whatever text follows it up to the next tag
+var ofxTagRe = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// ParseOFX reads every statement in an OFX file.
+// SGML (1.x) leaves leaf elements unclosed, so we only rely on aggregate open/close tags and
+// read leaf values as the text following their open tag; this works for XML (2.x) too.
+func ParseOFX(r io.Reader) ([]OFXStatement, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything before <OFX> is a header (SGML key:value lines or XML prolog)
+	start := bytes.Index(bytes.ToUpper(raw), []byte("<OFX>"))
+	if start < 0 {
+		return nil, errors.New("not an OFX file: missing <OFX> element")
+	}
+	body := string(raw[start:])
+
+	var (
+		statements []OFXStatement
+		stmt       *OFXStatement
+		tx         *OFXTransaction
+		path       []string // open aggregates, innermost last
+	)
+
+	inside := func(tag string) bool {
+		for _, p := range path {
+			if p == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, m := range ofxTagRe.FindAllStringSubmatch(body, -1) {
+		closing := m[1] == "/"
+		tag := strings.ToUpper(m[2])
+		value := strings.TrimSpace(ofxUnescape(m[3]))
+
+		if closing {
+			// Pop back to the matching aggregate; unclosed SGML leaves never got pushed
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == tag {
+					path = path[:i]
+					break
+				}
+			}
+			switch tag {
+			case "STMTTRN":
+				if stmt != nil && tx != nil {
+					stmt.Transactions = append(stmt.Transactions, *tx)
+				}
+				tx = nil
+			case "STMTRS", "CCSTMTRS":
+				if stmt != nil {
+					statements = append(statements, *stmt)
+				}
+				stmt = nil
+			}
+			continue
+		}
+
+		if value == "" {
+			// Aggregate opening tag (or an empty leaf, which we can ignore)
+			path = append(path, tag)
+			switch tag {
+			case "STMTRS", "CCSTMTRS":
+				stmt = &OFXStatement{}
+				if tag == "CCSTMTRS" {
+					stmt.AccountType = "CREDITCARD"
+				}
+			case "STMTTRN":
+				tx = &OFXTransaction{}
+			}
+			continue
+		}
+
+		if stmt == nil {
+			continue
+		}
+
+		switch {
+		case tx != nil && inside("STMTTRN"):
+			switch tag {
+			case "FITID":
+				tx.FITID = value
+			case "DTPOSTED":
+				tx.Posted, err = parseOFXDate(value)
+				if err != nil {
+					return nil, err
+				}
+			case "TRNAMT":
+				if tx.Amount, err = parseOFXAmount(value); err != nil {
+					return nil, err
+				}
+			case "NAME", "PAYEE":
+				tx.Name = value
+			case "MEMO":
+				tx.Memo = value
+			}
+		case inside("LEDGERBAL"):
+			switch tag {
+			case "BALAMT":
+				if stmt.Balance, err = parseOFXAmount(value); err != nil {
+					return nil, err
+				}
+			case "DTASOF":
+				if stmt.BalanceDate, err = parseOFXDate(value); err != nil {
+					return nil, err
+				}
+			}
+		case inside("AVAILBAL"):
+			if tag == "BALAMT" {
+				available, err := parseOFXAmount(value)
+				if err != nil {
+					return nil, err
+				}
+				stmt.Available = &available
+			}
+		case inside("BANKACCTFROM") || inside("CCACCTFROM"):
+			switch tag {
+			case "ACCTID":
+				stmt.AccountID = value
+			case "BANKID":
+				stmt.BankID = value
+			case "ACCTTYPE":
+				stmt.AccountType = value
+			}
+		case tag == "CURDEF":
+			stmt.Currency = value
+		}
+	}
+
+	if len(statements) == 0 {
+		return nil, errors.New("no statements found in OFX file")
+	}
+	return statements, nil
+}
+
+// Import parses an OFX upload and stores its transactions, keyed by FITID
+func (s *OFXImportService) Import(r io.Reader) (*OFXImportResult, error) {
+	statements, err := ParseOFX(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OFXImportResult{Accounts: []string{}, Errors: []string{}}
+	for _, stmt := range statements {
+		if stmt.AccountID == "" {
+			result.Errors = append(result.Errors, "statement without ACCTID skipped")
+			continue
+		}
+		accountID := ofxAccountID(stmt)
+		currency := stmt.Currency
+		if currency == "" {
+			currency = "USD"
+		}
+
+		s.upsertAccount(accountID, stmt, currency)
+		result.Accounts = append(result.Accounts, accountID)
+
+		for _, t := range stmt.Transactions {
+			if t.FITID == "" || t.Posted == "" {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: transaction without FITID/DTPOSTED skipped", accountID))
+				continue
+			}
+
+			payee := t.Name
+			if payee == "" {
+				payee = t.Memo
+			}
+			// FITID is only unique per account, so namespace it
+			txID := accountID + "_" + t.FITID
+
+			var existing database.Transaction
+			found := s.DB.Limit(1).Find(&existing, "id = ?", txID)
+
+			if found.RowsAffected == 0 {
+				tx := database.Transaction{
+					ID:             txID,
+					Provider:       "ofx",
+					AccountID:      accountID,
+					Date:           t.Posted,
+					Payee:          payee,
+					RawPayee:       payee,
+					Amount:         t.Amount,
+					Currency:       currency,
+					LedgerCategory: "Expenses:Uncategorized",
+					Notes:          t.Memo,
+					IsReviewed:     false,
+				}
+				s.Rules.Categorize(&tx)
+				if err := s.DB.Create(&tx).Error; err != nil {
+					result.Errors = append(result.Errors, err.Error())
+					continue
+				}
+				result.Imported++
+			} else {
+				// Banks occasionally correct pending amounts in later downloads
+				stored := existing
+				existing.Amount = t.Amount
+				existing.Date = t.Posted
+				existing.RawPayee = payee
+				if !existing.IsReviewed {
+					existing.Payee = payee
+					s.Rules.Recategorize(&existing, stored)
+				}
+				if existing.Amount != stored.Amount {
+					if err := dropStaleSplits(s.DB, &existing); err != nil {
+						result.Errors = append(result.Errors, err.Error())
+					}
+				}
+				s.DB.Save(&existing)
+				result.Updated++
+			}
+		}
+	}
+
+	fmt.Printf("[INFO] OFX import: %d new, %d updated across %d accounts\n", result.Imported, result.Updated, len(result.Accounts))
+	return result, nil
+}
+
+func (s *OFXImportService) upsertAccount(id string, stmt OFXStatement, currency string) {
+	s.EnsureAccount(id, ofxAccountName(stmt), currency)
+
+	var acc database.AccountMap
+	if err := s.DB.First(&acc, "external_id = ?", id).Error; err != nil {
+		return
+	}
+	acc.CurrentBalance = stmt.Balance
+	acc.AvailableBalance = stmt.Balance
+	if stmt.Available != nil {
+		acc.AvailableBalance = *stmt.Available
+	}
+	acc.Currency = currency
+	acc.LastUpdated = time.Now()
+	s.DB.Save(&acc)
+
+	if stmt.BalanceDate != "" {
+		if err := database.RecordBalance(s.DB, id, stmt.BalanceDate, stmt.Balance, currency); err != nil {
+			fmt.Printf("[WARN] Could not record balance for %s: %v\n", id, err)
+		}
+	}
+}
+
+func ofxAccountID(stmt OFXStatement) string {
+	if stmt.BankID != "" {
+		return fmt.Sprintf("ofx_%s_%s", stmt.BankID, stmt.AccountID)
+	}
+	return "ofx_" + stmt.AccountID
+}
+
+// Only show the last 4 digits of account numbers in the UI
+func ofxAccountName(stmt OFXStatement) string {
+	last4 := stmt.AccountID
+	if len(last4) > 4 {
+		last4 = last4[len(last4)-4:]
+	}
+	kind := strings.ToLower(stmt.AccountType)
+	if kind == "" {
+		kind = "account"
+	}
+	return fmt.Sprintf("%s%s ...%s", strings.ToUpper(kind[:1]), kind[1:], last4)
+}
+
+// parseOFXAmount reads an OFX amount. The spec allows "," as the decimal separator
+// ("-12,34") and has no thousands grouping, so a lone comma is the decimal point.
+func parseOFXAmount(v string) (database.Money, error) {
+	if strings.Count(v, ",") == 1 && !strings.Contains(v, ".") {
+		v = strings.Replace(v, ",", ".", 1)
+	}
+	return database.ParseMoney(v)
+}
+
+// parseOFXDate handles YYYYMMDD[HHMMSS[.XXX]][[-5:EST]]; we only need the day
+func parseOFXDate(v string) (string, error) {
+	if len(v) < 8 {
+		return "", fmt.Errorf("invalid OFX date %q", v)
+	}
+	t, err := time.Parse("20060102", v[:8])
+	if err != nil {
+		return "", fmt.Errorf("invalid OFX date %q", v)
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+var ofxEntities = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&nbsp;", " ")
+
+func ofxUnescape(s string) string {
+	return ofxEntities.Replace(s)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STMTRS><CURDEF>EUR
+<BANKACCTFROM><BANKID>123456<ACCTID>99887766<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST><DTSTART>20240101<DTEND>20240110
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240105120000[-5:EST]<TRNAMT>-12,34<FITID>A1<NAME>LYFT RIDE<MEMO>Ride &amp; tip</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20240106<TRNAMT>2500.00<FITID>A2<NAME>ACME PAYROLL</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>1234,56<DTASOF>20240110</LEDGERBAL>
+<AVAILBAL><BALAMT>1000.00<DTASOF>20240110</AVAILBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const xmlStatement = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <CREDITCARDMSGSRSV1><CCSTMTTRNRS><TRNUID>1</TRNUID>
+    <CCSTMTRS>
+      <CURDEF>USD</CURDEF>
+      <CCACCTFROM><ACCTID>4111222233334444</ACCTID></CCACCTFROM>
+      <BANKTRANLIST>
+        <STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20240203</DTPOSTED><TRNAMT>-9.99</TRNAMT><FITID>X1</FITID><NAME>NETFLIX</NAME><MEMO></MEMO></STMTTRN>
+      </BANKTRANLIST>
+      <LEDGERBAL><BALAMT>-9.99</BALAMT><DTASOF>20240205</DTASOF></LEDGERBAL>
+    </CCSTMTRS>
+  </CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFXSGML(t *testing.T) {
+	stmts, err := ParseOFX(strings.NewReader(sgmlStatement))
+	if err != nil {
+		t.Fatalf("ParseOFX: %v", err)
+	}
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements, want 1", len(stmts))
+	}
+	s := stmts[0]
+	if s.AccountID != "99887766" || s.BankID != "123456" || s.AccountType != "CHECKING" || s.Currency != "EUR" {
+		t.Errorf("account = %+v", s)
+	}
+	if s.Balance != 123456 || s.BalanceDate != "2024-01-10" {
+		t.Errorf("ledger balance = %s on %q, want 1234.56 on 2024-01-10", s.Balance, s.BalanceDate)
+	}
+	if s.Available == nil || *s.Available != 100000 {
+		t.Errorf("available balance = %v, want 1000.00", s.Available)
+	}
+
+	want := []OFXTransaction{
+		{FITID: "A1", Posted: "2024-01-05", Amount: -1234, Name: "LYFT RIDE", Memo: "Ride & tip"},
+		{FITID: "A2", Posted: "2024-01-06", Amount: 250000, Name: "ACME PAYROLL"},
+	}
+	if len(s.Transactions) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(s.Transactions), len(want))
+	}
+	for i, tx := range s.Transactions {
+		if tx != want[i] {
+			t.Errorf("transaction %d = %+v, want %+v", i, tx, want[i])
+		}
+	}
+}
+
+func TestParseOFXXML(t *testing.T) {
+	stmts, err := ParseOFX(strings.NewReader(xmlStatement))
+	if err != nil {
+		t.Fatalf("ParseOFX: %v", err)
+	}
+	if len(stmts) != 1 {
+		t.Fatalf("got %d statements, want 1", len(stmts))
+	}
+	s := stmts[0]
+	if s.AccountID != "4111222233334444" || s.AccountType != "CREDITCARD" {
+		t.Errorf("account = %+v", s)
+	}
+	if s.Balance != -999 || s.Available != nil {
+		t.Errorf("balance = %s, available = %v; want -9.99 and none", s.Balance, s.Available)
+	}
+	want := OFXTransaction{FITID: "X1", Posted: "2024-02-03", Amount: -999, Name: "NETFLIX"}
+	if len(s.Transactions) != 1 || s.Transactions[0] != want {
+		t.Errorf("transactions = %+v, want [%+v]", s.Transactions, want)
+	}
+}
+
+func TestParseOFXErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"not ofx":       "Date,Amount\n2024-01-01,5",
+		"no statements": "<OFX><SIGNONMSGSRSV1></SIGNONMSGSRSV1></OFX>",
+		"bad amount":    strings.Replace(xmlStatement, "-9.99</TRNAMT>", "abc</TRNAMT>", 1),
+		"bad date":      strings.Replace(xmlStatement, "<DTPOSTED>20240203", "<DTPOSTED>2024", 1),
+	} {
+		if _, err := ParseOFX(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: ParseOFX succeeded, want error", name)
+		}
+	}
+}
+
+func TestParseOFXAmount(t *testing.T) {
+	tests := map[string]database.Money{"-12.34": -1234, "-12,34": -1234, "12,3": 1230, "+5": 500, "0,005": 1}
+	for in, want := range tests {
+		got, err := parseOFXAmount(in)
+		if err != nil || got != want {
+			t.Errorf("parseOFXAmount(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+}
+
+func TestOFXImportBalances(t *testing.T) {
+	db := newTestDB(t)
+	importer := NewOFXImportService(db, NewRuleEngine(db))
+
+	result, err := importer.Import(strings.NewReader(sgmlStatement))
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if result.Imported != 2 || len(result.Errors) > 0 {
+		t.Fatalf("Import = %+v, want 2 imported", result)
+	}
+
+	var acc database.AccountMap
+	if err := db.First(&acc, "external_id = ?", "ofx_123456_99887766").Error; err != nil {
+		t.Fatalf("account: %v", err)
+	}
+	if acc.CurrentBalance != 123456 || acc.AvailableBalance != 100000 {
+		t.Errorf("balances = %s / %s, want 1234.56 / 1000.00", acc.CurrentBalance, acc.AvailableBalance)
+	}
+
+	var tx database.Transaction
+	if err := db.First(&tx, "id = ?", "ofx_123456_99887766_A1").Error; err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+	if tx.Amount != -1234 || tx.Currency != "EUR" {
+		t.Errorf("transaction = %s %s, want -12.34 EUR", tx.Amount, tx.Currency)
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"time"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+// Provider is a data source that feeds transactions into the DB.
+// New sources implement this and get registered in main.go; nothing else needs to know their name.
+type Provider interface {
+	// Name is the value stored in AccountMap.Provider
+	Name() string
+	// Labels lists every Transaction.Provider value this source writes
+	Labels() []string
+	// Sync pulls new data from the remote. Import-only sources return nil.
+	Sync() error
+	// EnsureAccount creates the AccountMap row for an external account if it's missing
+	EnsureAccount(externalID, name, currency string)
+	// DefaultLedgerAccount is used for new accounts until the user maps them
+	DefaultLedgerAccount(externalID string) string
+	// ExportHint tells the ledger exporter how to treat one of this source's transactions
+	ExportHint(tx database.Transaction) ExportHint
+}
+
+// Backfiller is implemented by providers that can import history on demand
+type Backfiller interface {
+	Backfill(from time.Time) error
+}
+
+// ExportHint overrides the exporter's default handling of a transaction
+type ExportHint struct {
+	Skip          bool   // Leave the transaction out of the journal entirely
+	SourceAccount string // Use this instead of the mapped AccountMap.LedgerAccount
+}
+
+type ProviderRegistry struct {
+	providers []Provider
+	byLabel   map[string]Provider
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{byLabel: make(map[string]Provider)}
+}
+
+// Register adds a provider. Sync order follows registration order.
+func (r *ProviderRegistry) Register(p Provider) {
+	r.providers = append(r.providers, p)
+	for _, label := range p.Labels() {
+		r.byLabel[label] = p
+	}
+}
+
+func (r *ProviderRegistry) All() []Provider {
+	return r.providers
+}
+
+func (r *ProviderRegistry) Get(name string) Provider {
+	for _, p := range r.providers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// ForLabel finds the provider that owns a Transaction.Provider value
+func (r *ProviderRegistry) ForLabel(label string) Provider {
+	if r == nil {
+		return nil
+	}
+	return r.byLabel[label]
+}
+
+// ensureProviderAccount is the shared EnsureAccount implementation
+func ensureProviderAccount(db *gorm.DB, p Provider, externalID, name, currency string) {
+	var count int64
+	db.Model(&database.AccountMap{}).Where("external_id = ?", externalID).Count(&count)
+	if count == 0 {
+		db.Create(&database.AccountMap{
+			ExternalID:    externalID,
+			Provider:      p.Name(),
+			Name:          name,
+			LedgerAccount: p.DefaultLedgerAccount(externalID),
+			Currency:      currency,
+			LastUpdated:   time.Now(),
+		})
+	}
+}
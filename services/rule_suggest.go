@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// Card processors prefix the merchant name: "SQ *BLUE BOTTLE", "TST* CAFE", "PAYPAL *STEAM"
+	processorPrefixRe = regexp.MustCompile(`^(?:SQ|TST|SP|PP|PAYPAL|PY|IN|BT|DD|FSP|GRD|LS|WPY|SMK|CKE)\s*\*\s*`)
+	// Bank boilerplate before the merchant. Each word must end at a space or dash, or
+	// "CARDINAL HEALTH" would lose its "CARD" and "POSTMATES" its "POS".
+	bankPrefixRe = regexp.MustCompile(`^(?:(?:POS|DEBIT CARD|CHECKCARD|CARD|ACH|RECURRING|PREAUTHORIZED)(?:\s+|\s*-\s*|$))?(?:(?:PURCHASE|DEBIT|PAYMENT)(?:\s+|\s*-\s*|$))?(?:-\s*)?`)
+	// Left over from "PAYMENT THANK YOU" once the prefix goes; it names no merchant
+	courtesyRe = regexp.MustCompile(`^(?:\W*(?:THANK|THANKS|THANKYOU|YOU|RECEIVED))+\W*$`)
+	// Posting dates/codes some banks put before the merchant ("CHECKCARD 0412 SAFEWAY")
+	leadingCodeRe = regexp.MustCompile(`^(?:[\d/\-]+\s+)+`)
+	// Everything from the first store number, date or reference code on is noise
+	descriptorNoiseRe = regexp.MustCompile(`\s*(?:#|\bNO\.?\s*\d|\d{2}/\d{2}|\d{3,}|\*).*$`)
+	usStateRe         = regexp.MustCompile(`^(?:A[KLRZ]|C[AOT]|D[CE]|FL|GA|HI|I[ADLN]|K[SY]|LA|M[ADEINOST]|N[CDEHJMVY]|O[HKR]|PA|RI|S[CD]|T[NX]|UT|V[AT]|W[AIVY])$`)
+)
+
+// ruleWordLimit keeps the pattern to the merchant name; city names usually come after it
+const ruleWordLimit = 3
+
+// GeneralizePayee turns a raw card descriptor into a regex for the merchant,
+// e.g. "SQ *BLUE BOTTLE 0423 SAN FRANCISCO CA" -> `(?i)\bblue\W*bottle\b`.
+// Returns "" if nothing recognizable is left.
+func GeneralizePayee(payee string) string {
+	s := strings.ToUpper(strings.TrimSpace(payee))
+	s = processorPrefixRe.ReplaceAllString(s, "")
+	if stripped := bankPrefixRe.ReplaceAllString(s, ""); stripped != "" && !courtesyRe.MatchString(stripped) {
+		s = stripped
+	}
+	s = leadingCodeRe.ReplaceAllString(s, "")
+	s = descriptorNoiseRe.ReplaceAllString(s, "")
+
+	var words []string
+	for _, w := range strings.Fields(s) {
+		w = strings.Trim(w, ".,-'&/")
+		if w == "" {
+			continue
+		}
+		if strings.ContainsAny(w, "0123456789") {
+			break
+		}
+		words = append(words, w)
+	}
+
+	// "... SAN FRANCISCO CA": with a trailing state the words before it are likely the city
+	if len(words) > 1 && usStateRe.MatchString(words[len(words)-1]) {
+		words = words[:len(words)-1]
+		if len(words) > 2 {
+			words = words[:2]
+		}
+	}
+	if len(words) > ruleWordLimit {
+		words = words[:ruleWordLimit]
+	}
+	if len(words) == 0 {
+		return ""
+	}
+
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(strings.ToLower(w))
+	}
+	return `(?i)\b` + strings.Join(words, `\W*`) + `\b`
+}
+
+// RuleSuggestion is a proposed rule for a transaction and what it would catch
+type RuleSuggestion struct {
+	Rule            database.CategoryRule `json:"rule"`
+	OtherUnreviewed int                   `json:"other_unreviewed"` // Unreviewed transactions besides this one it would match
+	Examples        []string              `json:"examples"`         // A few of their payees
+	Preview         *RulePreview          `json:"preview"`
+}
+
+// ErrNoRuleCategory means a suggested rule would only file matches as uncategorized
+var ErrNoRuleCategory = fmt.Errorf("%w: categorize the transaction first, a rule filing into %s would do nothing", ErrRuleInvalid, uncategorized)
+
+// SuggestRule proposes a rule that files transactions from the same merchant like txID.
+// category overrides the transaction's own; empty uses its category or pending suggestion.
+func (re *RuleEngine) SuggestRule(txID, category string) (*RuleSuggestion, error) {
+	var tx database.Transaction
+	if err := re.DB.First(&tx, "id = ?", txID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("transaction not found")
+		}
+		return nil, err
+	}
+
+	pattern := GeneralizePayee(tx.SourcePayee())
+	if pattern == "" {
+		// Nothing to generalize; match the exact descriptor
+		pattern = `(?i)^` + regexp.QuoteMeta(tx.SourcePayee()) + `$`
+	}
+	if category == "" {
+		category = tx.LedgerCategory
+		if category == uncategorized && tx.SuggestedCategory != "" {
+			category = tx.SuggestedCategory
+		}
+	}
+	if category == "" || category == uncategorized {
+		return nil, ErrNoRuleCategory
+	}
+
+	rule := database.CategoryRule{Pattern: pattern, Category: category, Priority: 10}
+	preview, err := re.Preview(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestion := &RuleSuggestion{Rule: rule, Examples: []string{}, Preview: preview}
+	seen := map[string]bool{}
+	for _, m := range preview.Matches {
+		if m.ID == tx.ID || m.IsReviewed {
+			continue
+		}
+		suggestion.OtherUnreviewed++
+		if len(suggestion.Examples) < 5 && !seen[m.FromPayee] {
+			seen[m.FromPayee] = true
+			suggestion.Examples = append(suggestion.Examples, m.FromPayee)
+		}
+	}
+	return suggestion, nil
+}
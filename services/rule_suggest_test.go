@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestGeneralizePayee(t *testing.T) {
+	tests := []struct {
+		payee string
+		want  string
+	}{
+		{"SQ *BLUE BOTTLE 0423 SAN FRANCISCO CA", `(?i)\bblue\W*bottle\b`},
+		{"TST* CAFE BORRONE", `(?i)\bcafe\W*borrone\b`},
+		{"POS PURCHASE - SAFEWAY #1234", `(?i)\bsafeway\b`},
+		{"DEBIT CARD PURCHASE TRADER JOES 552", `(?i)\btrader\W*joes\b`},
+		{"CHECKCARD 0412 SHELL OIL 5744", `(?i)\bshell\W*oil\b`},
+		{"ACH DEBIT COMCAST", `(?i)\bcomcast\b`},
+		{"RECURRING PAYMENT-NETFLIX.COM", `(?i)\bnetflix\.com\b`},
+		// Merchants that merely start like bank boilerplate keep their name
+		{"CARDINAL HEALTH", `(?i)\bcardinal\W*health\b`},
+		{"POSTMATES", `(?i)\bpostmates\b`},
+		{"ACHIEVE FITNESS", `(?i)\bachieve\W*fitness\b`},
+		{"PAYMENTUS CORP", `(?i)\bpaymentus\W*corp\b`},
+		{"DEBITNOTE INC", `(?i)\bdebitnote\W*inc\b`},
+		{"PURCHASE", `(?i)\bpurchase\b`},
+		// Stripping the prefix would leave a pattern matching every "thank you" descriptor
+		{"Payment Thank You", `(?i)\bpayment\W*thank\W*you\b`},
+		{"PAYMENT RECEIVED - THANK YOU", `(?i)\bpayment\W*received\W*thank\b`},
+		{"12345", ""},
+	}
+	for _, tt := range tests {
+		got := GeneralizePayee(tt.payee)
+		if got != tt.want {
+			t.Errorf("GeneralizePayee(%q) = %q, want %q", tt.payee, got, tt.want)
+			continue
+		}
+		// A suggested rule must at least catch the transaction it was built from
+		if got != "" && !regexp.MustCompile(got).MatchString(tt.payee) {
+			t.Errorf("GeneralizePayee(%q) = %q doesn't match its own payee", tt.payee, got)
+		}
+	}
+}
+
+func TestSuggestRule(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]database.Transaction{
+		{ID: "a", Payee: "SQ *BLUE BOTTLE 0423", Amount: -550, LedgerCategory: "Expenses:Coffee", IsReviewed: true},
+		{ID: "b", Payee: "SQ *BLUE BOTTLE 0977", Amount: -600, LedgerCategory: uncategorized},
+		{ID: "c", Payee: "CARDINAL HEALTH", Amount: -2000, LedgerCategory: uncategorized},
+	})
+	re := NewRuleEngine(db)
+
+	s, err := re.SuggestRule("a", "")
+	if err != nil {
+		t.Fatalf("SuggestRule: %v", err)
+	}
+	if s.Rule.Pattern != `(?i)\bblue\W*bottle\b` || s.Rule.Category != "Expenses:Coffee" || s.OtherUnreviewed != 1 {
+		t.Errorf("suggestion = %+v", s)
+	}
+
+	if _, err := re.SuggestRule("c", ""); !errors.Is(err, ErrRuleInvalid) {
+		t.Errorf("SuggestRule on an uncategorized row: err = %v, want ErrRuleInvalid", err)
+	}
+	s, err = re.SuggestRule("c", "Expenses:Health")
+	if err != nil || s.Rule.Category != "Expenses:Health" || s.Rule.Pattern != `(?i)\bcardinal\W*health\b` {
+		t.Errorf("SuggestRule with a category = %+v, %v", s, err)
+	}
+
+	if _, err := re.SuggestRule("missing", ""); err == nil || errors.Is(err, ErrRuleInvalid) {
+		t.Errorf("SuggestRule on a missing row: err = %v, want not found", err)
+	}
+}
@@ -1,8 +1,12 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"expense_tracker/database"
 
@@ -12,13 +16,23 @@ import (
 type RuleEngine struct {
 	DB    *gorm.DB
 	Rules []CompiledRule
+	// Classifier, when set, suggests a category for transactions no rule categorizes
+	Classifier *Classifier
 }
 
+// CompiledRule is a CategoryRule with its regexes compiled
 type CompiledRule struct {
-	Regex    *regexp.Regexp
-	Category string
+	database.CategoryRule
+	Regex      *regexp.Regexp
+	NotesRegex *regexp.Regexp // nil when the rule has no notes condition
 }
 
+var (
+	ErrRuleNotFound = errors.New("rule not found")
+	ErrRuleInvalid  = errors.New("invalid rule")
+	ErrRuleConflict = errors.New("rule conflicts with an existing rule")
+)
+
 func NewRuleEngine(db *gorm.DB) *RuleEngine {
 	re := &RuleEngine{DB: db}
 	re.Reload()
@@ -27,31 +41,501 @@ func NewRuleEngine(db *gorm.DB) *RuleEngine {
 
 func (re *RuleEngine) Reload() {
 	var dbRules []database.CategoryRule
-	re.DB.Order("priority desc").Find(&dbRules)
+	re.DB.Order("priority desc, id asc").Find(&dbRules)
 
 	var compiled []CompiledRule
 	for _, r := range dbRules {
-		regex, err := regexp.Compile(r.Pattern)
+		rule, err := CompileRule(r)
 		if err != nil {
-			fmt.Printf("[WARN] Invalid Regex Rule '%s': %v\n", r.Pattern, err)
+			fmt.Printf("[WARN] Skipping Rule %d '%s': %v\n", r.ID, r.Pattern, err)
 			continue
 		}
-		compiled = append(compiled, CompiledRule{
-			Regex:    regex,
-			Category: r.Category,
-		})
+		compiled = append(compiled, rule)
 	}
 	re.Rules = compiled
 	fmt.Printf("[INFO] Loaded %d auto-categorization rules\n", len(re.Rules))
 }
 
-func (re *RuleEngine) Apply(payee string) string {
-	for _, rule := range re.Rules {
-		if rule.Regex.MatchString(payee) {
-			return rule.Category
+// CompileRule validates a rule and compiles its regexes
+func CompileRule(r database.CategoryRule) (CompiledRule, error) {
+	regex, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return CompiledRule{}, fmt.Errorf("invalid pattern: %v", err)
+	}
+	rule := CompiledRule{CategoryRule: r, Regex: regex}
+
+	if r.NotesPattern != "" {
+		if rule.NotesRegex, err = regexp.Compile(r.NotesPattern); err != nil {
+			return CompiledRule{}, fmt.Errorf("invalid notes pattern: %v", err)
+		}
+	}
+	if r.Sign != "" && r.Sign != "debit" && r.Sign != "credit" {
+		return CompiledRule{}, fmt.Errorf("sign must be debit or credit, got %q", r.Sign)
+	}
+	if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+		return CompiledRule{}, errors.New("min amount is greater than max amount")
+	}
+	for _, d := range []string{r.DateFrom, r.DateTo} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return CompiledRule{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", d)
+		}
+	}
+	// An empty rule would swallow every transaction
+	if r.Pattern == "" && !rule.hasConditions() {
+		return CompiledRule{}, errors.New("rule needs a pattern or at least one condition")
+	}
+	if r.Category == "" && r.PayeeRewrite == "" && r.AppendNotes == "" && len(r.Tags) == 0 && !r.MarkReviewed {
+		return CompiledRule{}, errors.New("rule has no actions")
+	}
+	return rule, nil
+}
+
+func (r CompiledRule) hasConditions() bool {
+	return r.MinAmount != nil || r.MaxAmount != nil || r.Sign != "" || r.AccountID != "" ||
+		r.Provider != "" || r.Currency != "" || r.DateFrom != "" || r.DateTo != "" || r.NotesPattern != ""
+}
+
+// Matches reports whether every condition on the rule holds for tx
+func (r CompiledRule) Matches(tx database.Transaction) bool {
+	if !r.Regex.MatchString(tx.SourcePayee()) {
+		return false
+	}
+
+	switch r.Sign {
+	case "debit":
+		if tx.Amount >= 0 {
+			return false
+		}
+	case "credit":
+		if tx.Amount <= 0 {
+			return false
+		}
+	}
+
+	amt := tx.Amount.Abs()
+	if r.MinAmount != nil && amt < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && amt > *r.MaxAmount {
+		return false
+	}
+
+	if r.AccountID != "" && r.AccountID != tx.AccountID {
+		return false
+	}
+	if r.Provider != "" && r.Provider != tx.Provider {
+		return false
+	}
+	if r.Currency != "" && !strings.EqualFold(r.Currency, tx.Currency) {
+		return false
+	}
+
+	// Dates are stored as YYYY-MM-DD so string order is date order
+	if r.DateFrom != "" && tx.Date < r.DateFrom {
+		return false
+	}
+	if r.DateTo != "" && tx.Date > r.DateTo {
+		return false
+	}
+
+	if r.NotesRegex != nil && !r.NotesRegex.MatchString(tx.Notes) {
+		return false
+	}
+	return true
+}
+
+// Execute runs the rule's actions on tx. It's safe to run repeatedly on the same transaction.
+func (r CompiledRule) Execute(tx *database.Transaction) {
+	if r.Category != "" {
+		tx.LedgerCategory = r.Category
+	}
+
+	if r.PayeeRewrite != "" {
+		src := tx.SourcePayee()
+		match := r.Regex.FindStringSubmatchIndex(src)
+		if payee := strings.TrimSpace(string(r.Regex.ExpandString(nil, r.PayeeRewrite, src, match))); payee != "" {
+			tx.RawPayee = src
+			tx.Payee = payee
+		}
+	}
+
+	if r.AppendNotes != "" && !strings.Contains(tx.Notes, r.AppendNotes) {
+		if tx.Notes == "" {
+			tx.Notes = r.AppendNotes
+		} else {
+			tx.Notes += "; " + r.AppendNotes
+		}
+	}
+
+	tx.Tags = AddTags(tx.Tags, r.Tags)
+
+	if r.MarkReviewed {
+		tx.IsReviewed = true
+	}
+}
+
+// Apply runs the first (highest priority) rule matching tx and reports whether it changed anything.
+// Only changes count as hits, so re-applying rules to the same row doesn't inflate the stats.
+func (re *RuleEngine) Apply(tx *database.Transaction) bool {
+	return re.applyFrom(tx, *tx)
+}
+
+// applyFrom is Apply, judging changes against stored (the row as it is in the DB) rather
+// than tx as passed in. Sync resets a row's payee to the source's before re-running rules,
+// so against tx every payee rewrite would look like a change.
+func (re *RuleEngine) applyFrom(tx *database.Transaction, stored database.Transaction) bool {
+	i := firstMatch(re.Rules, *tx)
+	if i < 0 {
+		return false
+	}
+
+	stored.Tags = slices.Clone(stored.Tags)
+	re.Rules[i].Execute(tx)
+	if !ruleChangedTransaction(stored, *tx) {
+		return false
+	}
+
+	re.DB.Model(&database.CategoryRule{}).Where("id = ?", re.Rules[i].ID).Updates(map[string]interface{}{
+		"hit_count":    gorm.Expr("hit_count + 1"),
+		"last_matched": time.Now(),
+	})
+	return true
+}
+
+// Categorize runs the rules, then falls back to a learned suggestion if tx is still uncategorized
+func (re *RuleEngine) Categorize(tx *database.Transaction) {
+	re.categorize(tx, *tx)
+}
+
+// Recategorize is Categorize for a row that's already stored: tx has been refreshed from the
+// source, stored is the row as loaded from the DB. Rule hits only count if the result differs
+// from stored.
+func (re *RuleEngine) Recategorize(tx *database.Transaction, stored database.Transaction) {
+	re.categorize(tx, stored)
+}
+
+func (re *RuleEngine) categorize(tx *database.Transaction, stored database.Transaction) {
+	re.applyFrom(tx, stored)
+
+	if tx.LedgerCategory != uncategorized || tx.IsReviewed {
+		tx.ClearSuggestion()
+		return
+	}
+	// Keep an LLM suggestion; it was asked for explicitly and is costlier to redo
+	if re.Classifier == nil || tx.SuggestionSource == SuggestionLLM {
+		return
+	}
+	tx.ClearSuggestion()
+	if cat, conf := re.Classifier.Suggest(*tx); cat != "" {
+		tx.SuggestedCategory, tx.SuggestionConfidence, tx.SuggestionSource = cat, conf, SuggestionBayes
+	}
+}
+
+// firstMatch returns the index of the rule that would fire for tx, or -1
+func firstMatch(rules []CompiledRule, tx database.Transaction) int {
+	for i, rule := range rules {
+		if rule.Matches(tx) {
+			return i
 		}
 	}
-	return ""
+	return -1
+}
+
+// Create validates and stores a new rule
+func (re *RuleEngine) Create(rule *database.CategoryRule) error {
+	rule.ID = 0
+	rule.HitCount = 0
+	rule.LastMatched = nil
+	if err := re.validate(rule); err != nil {
+		return err
+	}
+	if err := re.DB.Create(rule).Error; err != nil {
+		return err
+	}
+	re.Reload()
+	return nil
+}
+
+// Update replaces every field of an existing rule except its usage stats
+func (re *RuleEngine) Update(rule *database.CategoryRule) error {
+	var count int64
+	re.DB.Model(&database.CategoryRule{}).Where("id = ?", rule.ID).Count(&count)
+	if count == 0 {
+		return ErrRuleNotFound
+	}
+	if err := re.validate(rule); err != nil {
+		return err
+	}
+	if err := re.DB.Omit("hit_count", "last_matched").Save(rule).Error; err != nil {
+		return err
+	}
+	re.Reload()
+	return nil
+}
+
+func (re *RuleEngine) Delete(id uint) error {
+	res := re.DB.Delete(&database.CategoryRule{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRuleNotFound
+	}
+	re.Reload()
+	return nil
+}
+
+// Reorder rewrites priorities so rules run in the given order (first ID runs first).
+// Rules left out of ids keep their priority.
+func (re *RuleEngine) Reorder(ids []uint) error {
+	err := re.DB.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			res := tx.Model(&database.CategoryRule{}).Where("id = ?", id).Update("priority", (len(ids)-i)*10)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("%w: %d", ErrRuleNotFound, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	re.Reload()
+	return nil
+}
+
+// validate compiles the rule and rejects one that matches exactly what another rule matches,
+// since the lower priority copy could never fire
+func (re *RuleEngine) validate(rule *database.CategoryRule) error {
+	rule.Category = strings.TrimSpace(rule.Category)
+	tags, err := NormalizeTags(rule.Tags)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRuleInvalid, err)
+	}
+	rule.Tags = tags
+	if _, err := CompileRule(*rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrRuleInvalid, err)
+	}
+
+	var others []database.CategoryRule
+	if err := re.DB.Where("pattern = ? AND id <> ?", rule.Pattern, rule.ID).Find(&others).Error; err != nil {
+		return err
+	}
+	for _, o := range others {
+		if sameRuleConditions(*rule, o) {
+			return fmt.Errorf("%w: rule %d has the same pattern and conditions", ErrRuleConflict, o.ID)
+		}
+	}
+	return nil
+}
+
+func sameRuleConditions(a, b database.CategoryRule) bool {
+	moneyEqual := func(x, y *database.Money) bool {
+		if x == nil || y == nil {
+			return x == y
+		}
+		return *x == *y
+	}
+	return a.Pattern == b.Pattern && moneyEqual(a.MinAmount, b.MinAmount) && moneyEqual(a.MaxAmount, b.MaxAmount) &&
+		a.Sign == b.Sign && a.AccountID == b.AccountID && a.Provider == b.Provider &&
+		strings.EqualFold(a.Currency, b.Currency) && a.DateFrom == b.DateFrom && a.DateTo == b.DateTo &&
+		a.NotesPattern == b.NotesPattern
+}
+
+// RulePreview is what saving a rule would do to the existing transactions
+type RulePreview struct {
+	Matched     int                 `json:"matched"`      // Transactions the rule's conditions match
+	WouldChange int                 `json:"would_change"` // Of those, how many "Run Rules" would actually modify
+	Shadowed    int                 `json:"shadowed"`     // Matched but a higher priority rule fires first
+	ShadowedBy  []RuleShadow        `json:"shadowed_by"`
+	Matches     []RulePreviewChange `json:"matches"`
+}
+
+type RuleShadow struct {
+	ID       uint   `json:"id"`
+	Pattern  string `json:"pattern"`
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+type RulePreviewChange struct {
+	ID           string         `json:"id"`
+	Date         string         `json:"date"`
+	Amount       database.Money `json:"amount"`
+	AccountID    string         `json:"account_id"`
+	FromPayee    string         `json:"from_payee"`
+	ToPayee      string         `json:"to_payee"`
+	FromCategory string         `json:"from_category"`
+	ToCategory   string         `json:"to_category"`
+	IsReviewed   bool           `json:"is_reviewed"`           // Reviewed rows are never touched by "Run Rules"
+	ShadowedBy   uint           `json:"shadowed_by,omitempty"` // ID of the rule that fires instead
+	WouldChange  bool           `json:"would_change"`
+}
+
+// Preview dry-runs a candidate rule against every non-voided transaction without writing anything.
+// If the candidate has an ID it stands in for that stored rule, so edits can be previewed too.
+func (re *RuleEngine) Preview(candidate database.CategoryRule) (*RulePreview, error) {
+	rule, err := CompileRule(candidate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRuleInvalid, err)
+	}
+
+	// Slot the candidate in where Reload would put it (priority desc, id asc; new rules sort last)
+	var rules []CompiledRule
+	self := -1
+	for _, r := range re.Rules {
+		if r.ID == candidate.ID {
+			continue
+		}
+		if self < 0 && (r.Priority < rule.Priority || (r.Priority == rule.Priority && candidate.ID != 0 && r.ID > candidate.ID)) {
+			self = len(rules)
+			rules = append(rules, rule)
+		}
+		rules = append(rules, r)
+	}
+	if self < 0 {
+		self = len(rules)
+		rules = append(rules, rule)
+	}
+
+	var txs []database.Transaction
+	if err := re.DB.Where("is_voided = ?", false).Order("date desc").Find(&txs).Error; err != nil {
+		return nil, err
+	}
+
+	preview := &RulePreview{ShadowedBy: []RuleShadow{}, Matches: []RulePreviewChange{}}
+	shadows := make(map[uint]*RuleShadow)
+	for _, tx := range txs {
+		if !rule.Matches(tx) {
+			continue
+		}
+		preview.Matched++
+
+		after := tx
+		after.Tags = slices.Clone(tx.Tags)
+		rule.Execute(&after)
+
+		change := RulePreviewChange{
+			ID:           tx.ID,
+			Date:         tx.Date,
+			Amount:       tx.Amount,
+			AccountID:    tx.AccountID,
+			FromPayee:    tx.Payee,
+			ToPayee:      after.Payee,
+			FromCategory: tx.LedgerCategory,
+			ToCategory:   after.LedgerCategory,
+			IsReviewed:   tx.IsReviewed,
+		}
+
+		if i := firstMatch(rules, tx); i != self {
+			winner := rules[i]
+			change.ShadowedBy = winner.ID
+			preview.Shadowed++
+			if shadows[winner.ID] == nil {
+				shadows[winner.ID] = &RuleShadow{ID: winner.ID, Pattern: winner.Pattern, Category: winner.Category}
+			}
+			shadows[winner.ID].Count++
+		} else if !tx.IsReviewed && ruleChangedTransaction(tx, after) {
+			change.WouldChange = true
+			preview.WouldChange++
+		}
+		preview.Matches = append(preview.Matches, change)
+	}
+
+	for _, sh := range shadows {
+		preview.ShadowedBy = append(preview.ShadowedBy, *sh)
+	}
+	slices.SortFunc(preview.ShadowedBy, func(a, b RuleShadow) int { return b.Count - a.Count })
+	return preview, nil
+}
+
+// Rule health, as reported by Report
+const (
+	RuleActive       = "active"
+	RuleNeverMatches = "never_matches" // No current transaction meets its conditions
+	RuleShadowed     = "shadowed"      // Matches, but a higher priority rule always fires first
+	RuleInvalid      = "invalid"       // Failed to compile, so it's not loaded
+)
+
+// RuleReport is a stored rule plus how it fares against the current transactions
+type RuleReport struct {
+	database.CategoryRule
+	MatchCount int    // Non-voided transactions its conditions match
+	FireCount  int    // Of those, how many it's the first match for
+	ShadowedBy []uint // Rules that fire instead on the rest
+	Status     string
+}
+
+// Report evaluates every stored rule against all non-voided transactions, in priority order
+func (re *RuleEngine) Report() ([]RuleReport, error) {
+	var stored []database.CategoryRule
+	if err := re.DB.Order("priority desc, id asc").Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	var txs []database.Transaction
+	if err := re.DB.Where("is_voided = ?", false).Find(&txs).Error; err != nil {
+		return nil, err
+	}
+
+	reports := make([]RuleReport, len(stored))
+	var compiled []CompiledRule
+	var reportIdx []int // compiled[i] belongs to reports[reportIdx[i]]
+	for i, r := range stored {
+		reports[i] = RuleReport{CategoryRule: r, ShadowedBy: []uint{}}
+		rule, err := CompileRule(r)
+		if err != nil {
+			reports[i].Status = RuleInvalid
+			continue
+		}
+		compiled = append(compiled, rule)
+		reportIdx = append(reportIdx, i)
+	}
+
+	shadowedBy := make([]map[uint]bool, len(stored))
+	for _, tx := range txs {
+		winner := -1
+		for i, rule := range compiled {
+			if !rule.Matches(tx) {
+				continue
+			}
+			rep := &reports[reportIdx[i]]
+			rep.MatchCount++
+			if winner < 0 {
+				winner = i
+				rep.FireCount++
+				continue
+			}
+			if shadowedBy[reportIdx[i]] == nil {
+				shadowedBy[reportIdx[i]] = make(map[uint]bool)
+			}
+			shadowedBy[reportIdx[i]][compiled[winner].ID] = true
+		}
+	}
+
+	for i := range reports {
+		rep := &reports[i]
+		for id := range shadowedBy[i] {
+			rep.ShadowedBy = append(rep.ShadowedBy, id)
+		}
+		slices.Sort(rep.ShadowedBy)
+
+		switch {
+		case rep.Status == RuleInvalid:
+		case rep.MatchCount == 0:
+			rep.Status = RuleNeverMatches
+		case rep.FireCount == 0:
+			rep.Status = RuleShadowed
+		default:
+			rep.Status = RuleActive
+		}
+	}
+	return reports, nil
 }
 
 // Run rules on all unreviewed transactions in the DB
@@ -59,22 +543,27 @@ func (re *RuleEngine) ApplyToExisting() (int, error) {
 	var txs []database.Transaction
 
 	// Only touch transactions that haven't been manually reviewed yet
-	if err := re.DB.Where("is_reviewed = ?", false).Find(&txs).Error; err != nil {
+	if err := re.DB.Where("is_reviewed = ? AND is_voided = ?", false, false).Find(&txs).Error; err != nil {
 		return 0, err
 	}
 
 	count := 0
 	for _, tx := range txs {
-		match := re.Apply(tx.Payee)
-
-		// If we found a match, and it's different from the current category
-		if match != "" && match != tx.LedgerCategory {
-			tx.LedgerCategory = match
-			// We DO NOT set IsReviewed=true here.
-			// We want the user to still see them as "Pending" to verify the rule worked correctly.
-			re.DB.Save(&tx)
-			count++
+		// We DO NOT set IsReviewed=true here unless the rule asks for it.
+		// We want the user to still see them as "Pending" to verify the rule worked correctly.
+		if !re.Apply(&tx) {
+			continue
+		}
+		if tx.LedgerCategory != uncategorized {
+			tx.ClearSuggestion()
 		}
+		re.DB.Save(&tx)
+		count++
 	}
 	return count, nil
 }
+
+func ruleChangedTransaction(a, b database.Transaction) bool {
+	return a.LedgerCategory != b.LedgerCategory || a.Payee != b.Payee || a.Notes != b.Notes ||
+		a.IsReviewed != b.IsReviewed || !slices.Equal(a.Tags, b.Tags)
+}
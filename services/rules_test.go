@@ -0,0 +1,63 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestRuleExecute(t *testing.T) {
+	db := newTestDB(t)
+	re := NewRuleEngine(db)
+	rule := &database.CategoryRule{Pattern: `(?i)^SQ \*(?P<name>.+)$`, Category: "Expenses:Food", PayeeRewrite: "${name}",
+		AppendNotes: "square", Tags: []string{"trip:japan"}}
+	if err := re.Create(rule); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	tx := database.Transaction{Payee: "SQ *BLUE BOTTLE", RawPayee: "SQ *BLUE BOTTLE", Amount: -500, LedgerCategory: uncategorized}
+	if !re.Apply(&tx) {
+		t.Fatal("Apply reported no change")
+	}
+	if tx.Payee != "BLUE BOTTLE" || tx.RawPayee != "SQ *BLUE BOTTLE" || tx.LedgerCategory != "Expenses:Food" ||
+		tx.Notes != "square" || strings.Join(tx.Tags, ",") != "trip:japan" {
+		t.Errorf("after Apply: %+v", tx)
+	}
+
+	// Running it again changes nothing and doesn't count
+	if re.Apply(&tx) {
+		t.Error("second Apply reported a change")
+	}
+	db.First(rule, rule.ID)
+	if rule.HitCount != 1 || rule.LastMatched == nil {
+		t.Errorf("HitCount = %d, LastMatched = %v; want 1 and set", rule.HitCount, rule.LastMatched)
+	}
+}
+
+// Sync resets Payee to the source descriptor before re-running rules; that alone isn't a hit
+func TestRuleHitsNotInflatedByResync(t *testing.T) {
+	db := newTestDB(t)
+	rules := NewRuleEngine(db)
+	rule := &database.CategoryRule{Pattern: `(?i)netflix`, PayeeRewrite: "Netflix"}
+	if err := rules.Create(rule); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	importer := NewOFXImportService(db, rules)
+
+	for i := 0; i < 3; i++ {
+		if _, err := importer.Import(strings.NewReader(xmlStatement)); err != nil {
+			t.Fatalf("Import %d: %v", i, err)
+		}
+	}
+
+	db.First(rule, rule.ID)
+	if rule.HitCount != 1 {
+		t.Errorf("HitCount = %d after importing the same statement 3 times, want 1", rule.HitCount)
+	}
+	var tx database.Transaction
+	db.First(&tx, "id = ?", "ofx_4111222233334444_X1")
+	if tx.Payee != "Netflix" || tx.RawPayee != "NETFLIX" {
+		t.Errorf("payee = %q (raw %q), want the rewrite kept", tx.Payee, tx.RawPayee)
+	}
+}
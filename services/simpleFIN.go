@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -35,6 +36,22 @@ func NewSimpleFinService(db *gorm.DB, accessURL string, rules *RuleEngine) *Simp
 	}
 }
 
+func (s *SimpleFinService) Name() string     { return "simplefin" }
+func (s *SimpleFinService) Labels() []string { return []string{"simplefin"} }
+
+func (s *SimpleFinService) DefaultLedgerAccount(externalID string) string {
+	return "Assets:FIXME:" + externalID
+}
+
+func (s *SimpleFinService) EnsureAccount(externalID, name, currency string) {
+	ensureProviderAccount(s.DB, s, externalID, name, currency)
+}
+
+// Bank transactions use the mapped account as-is
+func (s *SimpleFinService) ExportHint(tx database.Transaction) ExportHint {
+	return ExportHint{}
+}
+
 // --- JSON Response Structures ---
 type SFResponse struct {
 	Errors   []string    `json:"errors"`
@@ -47,6 +64,7 @@ type SFAccount struct {
 	Currency         string          `json:"currency"`
 	Balance          string          `json:"balance"`           // String from API
 	AvailableBalance string          `json:"available-balance"` // String from API
+	BalanceDate      int64           `json:"balance-date"`      // Unix time the balance was read
 	Transactions     []SFTransaction `json:"transactions"`
 }
 
@@ -57,92 +75,225 @@ type SFTransaction struct {
 	Description string `json:"description"`
 }
 
-// Sync fetches data using the stored AccessURL
+const (
+	// The bridge rejects (or silently truncates) long ranges, so larger windows are split up
+	sfChunkDays = 60
+	// Re-fetch a few days before the watermark so pending transactions that posted late are picked up
+	sfOverlapDays = 7
+	// How far back the very first sync goes when no account has a watermark yet
+	sfInitialDays = 90
+	// Every account in a response gets its watermark moved, so one trailing the freshest by
+	// this much hasn't been returned in that long (closed, or removed from the bridge)
+	sfStaleDays = 30
+)
+
+// Sync fetches everything since the oldest per-account watermark. Accounts the bridge
+// stopped returning are left out, or their watermark would drag every sync back to it.
 func (s *SimpleFinService) Sync() error {
 	if s.AccessURL == "" {
 		return errors.New("SIMPLEFIN_ACCESS_URL is missing in .env")
 	}
 
-	// Log the URL we are hitting
-	fmt.Printf("Debug: Fetching from %s\n", s.AccessURL)
+	now := time.Now()
+	from := now.AddDate(0, 0, -sfInitialDays)
 
-	resp, err := http.Get(s.AccessURL)
-	if err != nil {
+	var accounts []database.AccountMap
+	if err := s.DB.Where("provider = ?", s.Name()).Find(&accounts).Error; err != nil {
 		return err
 	}
+	var newest time.Time
+	for _, a := range accounts {
+		if a.SyncedThrough.After(newest) {
+			newest = a.SyncedThrough
+		}
+	}
+	if len(accounts) > 0 {
+		oldest := now
+		for _, a := range accounts {
+			if a.SyncedThrough.IsZero() {
+				// A new account appeared; give it the full initial window
+				oldest = from
+				break
+			}
+			if a.SyncedThrough.Before(newest.AddDate(0, 0, -sfStaleDays)) {
+				fmt.Printf("[WARN] SimpleFIN: %s not returned since %s, skipping it (backfill to catch up)\n", a.Name, a.SyncedThrough.Format("2006-01-02"))
+				continue
+			}
+			if a.SyncedThrough.Before(oldest) {
+				oldest = a.SyncedThrough
+			}
+		}
+		from = oldest.AddDate(0, 0, -sfOverlapDays)
+	}
+
+	return s.SyncRange(from, now)
+}
+
+// Backfill imports history from the given date up to now
+func (s *SimpleFinService) Backfill(from time.Time) error {
+	if s.AccessURL == "" {
+		return errors.New("SIMPLEFIN_ACCESS_URL is missing in .env")
+	}
+	return s.SyncRange(from, time.Now())
+}
+
+// SyncRange walks [from, to] in sfChunkDays windows, oldest first, and stops at the first
+// window that fails. Watermarks only ever cover windows that were fully stored, so the
+// next sync picks up from the gap.
+func (s *SimpleFinService) SyncRange(from, to time.Time) error {
+	chunkStart := from
+	for chunkStart.Before(to) {
+		chunkEnd := chunkStart.AddDate(0, 0, sfChunkDays)
+		if chunkEnd.After(to) {
+			chunkEnd = to
+		}
+
+		sfResp, err := s.fetch(chunkStart, chunkEnd)
+		if err != nil {
+			return err
+		}
+		if err := s.process(sfResp, chunkEnd); err != nil {
+			return err
+		}
+
+		chunkStart = chunkEnd
+	}
+	return nil
+}
+
+// fetch requests a single start-date/end-date window from the bridge
+func (s *SimpleFinService) fetch(start, end time.Time) (*SFResponse, error) {
+	fmt.Printf("[INFO] SimpleFIN: fetching %s -> %s\n", start.Format("2006-01-02"), end.Format("2006-01-02"))
+
+	u, err := url.Parse(s.AccessURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("start-date", strconv.FormatInt(start.Unix(), 10))
+	q.Set("end-date", strconv.FormatInt(end.Unix(), 10))
+	u.RawQuery = q.Encode()
+
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("API Error: %d", resp.StatusCode)
+		return nil, fmt.Errorf("API Error: %d", resp.StatusCode)
 	}
 
 	var sfResp SFResponse
 	if err := json.NewDecoder(resp.Body).Decode(&sfResp); err != nil {
 		// This will catch if the JSON format is unexpected
-		return fmt.Errorf("JSON Decode Error: %v", err)
+		return nil, fmt.Errorf("JSON Decode Error: %v", err)
 	}
+	for _, msg := range sfResp.Errors {
+		fmt.Printf("[WARN] SimpleFIN: %s\n", msg)
+	}
+	return &sfResp, nil
+}
 
-	// DEBUG LOGGING
+// process stores one window's accounts and transactions, then advances each account's
+// watermark. An account whose rows didn't all save keeps its old watermark.
+func (s *SimpleFinService) process(sfResp *SFResponse, through time.Time) error {
+	var failed []string
 	fmt.Printf("Debug: API returned %d Accounts\n", len(sfResp.Accounts))
 
-	// Process Accounts & Transactions
 	for _, acc := range sfResp.Accounts {
 
 		fmt.Printf("   -> Account: %s (%s) has %d transactions\n", acc.Name, acc.ID, len(acc.Transactions))
 
 		// --- NEW: Parse Balances ---
-		currBal, _ := strconv.ParseFloat(acc.Balance, 64)
-		availBal, _ := strconv.ParseFloat(acc.AvailableBalance, 64)
+		currBal, _ := database.ParseMoney(acc.Balance)
+		availBal, _ := database.ParseMoney(acc.AvailableBalance)
 
 		// --- NEW: Update Account Map with Balances ---
-		s.upsertAccount(acc.ID, acc.Name, acc.Currency, currBal, availBal)
+		asOf := time.Now()
+		if acc.BalanceDate > 0 {
+			asOf = time.Unix(acc.BalanceDate, 0)
+		}
+		s.upsertAccount(acc.ID, acc.Name, acc.Currency, currBal, availBal, asOf)
 
+		var writeErr error
 		for _, t := range acc.Transactions {
 			tm := time.Unix(t.Posted, 0)
 			dateStr := tm.Format("2006-01-02")
-			amt, _ := strconv.ParseFloat(t.Amount, 64)
+			amt, err := database.ParseMoney(t.Amount)
+			if err != nil {
+				fmt.Printf("[WARN] Skipping SimpleFIN transaction %s: %v\n", t.ID, err)
+				continue
+			}
 
 			var existing database.Transaction
 			result := s.DB.Limit(1).Find(&existing, "id = ?", t.ID)
 
 			if result.RowsAffected == 0 {
 
-				cat := "Expenses:Uncategorized"
-
-				if match := s.Rules.Apply(t.Description); match != "" {
-					cat = match
-				}
-
 				// New Transaction
 				tx := database.Transaction{
 					ID:             t.ID,
-					Provider:       "simplefin",
+					Provider:       s.Name(),
 					AccountID:      acc.ID,
 					Date:           dateStr,
 					Payee:          t.Description,
+					RawPayee:       t.Description,
 					Amount:         amt,
 					Currency:       acc.Currency,
-					LedgerCategory: cat,
+					LedgerCategory: "Expenses:Uncategorized",
 					IsReviewed:     false,
 				}
-				s.DB.Create(&tx)
+
+				s.Rules.Categorize(&tx)
+				if err := s.DB.Create(&tx).Error; err != nil {
+					writeErr = fmt.Errorf("saving %s: %w", t.ID, err)
+				}
 			} else {
 				// Update existing
+				stored := existing
 				existing.Amount = amt
 				existing.Date = dateStr
+				existing.RawPayee = t.Description
 				if !existing.IsReviewed {
 					existing.Payee = t.Description
+					s.Rules.Recategorize(&existing, stored)
 				}
-				s.DB.Save(&existing)
+				if existing.Amount != stored.Amount {
+					if err := dropStaleSplits(s.DB, &existing); err != nil {
+						writeErr = fmt.Errorf("updating %s: %w", t.ID, err)
+					}
+				}
+				if err := s.DB.Save(&existing).Error; err != nil {
+					writeErr = fmt.Errorf("saving %s: %w", t.ID, err)
+				}
+			}
+		}
+		if writeErr != nil {
+			fmt.Printf("[ERROR] SimpleFIN: %s: %v\n", acc.Name, writeErr)
+			failed = append(failed, acc.Name)
+			continue
+		}
+
+		// Backfills cover old windows, so never move a watermark into the past
+		var mapping database.AccountMap
+		if s.DB.First(&mapping, "external_id = ?", acc.ID).Error == nil && mapping.SyncedThrough.Before(through) {
+			mapping.SyncedThrough = through
+			if err := s.DB.Save(&mapping).Error; err != nil {
+				return err
 			}
 		}
 	}
 	fmt.Printf("Synced %d Accounts via SimpleFIN\n", len(sfResp.Accounts))
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not save transactions for %s", strings.Join(failed, ", "))
+	}
 	return nil
 }
 
 // Renamed from ensureAccountExists to upsertAccount to handle updates
-func (s *SimpleFinService) upsertAccount(id, name, currency string, balance, available float64) {
+func (s *SimpleFinService) upsertAccount(id, name, currency string, balance, available database.Money, asOf time.Time) {
 	var acc database.AccountMap
 	result := s.DB.Limit(1).Find(&acc, "external_id = ?", id)
 
@@ -150,9 +301,9 @@ func (s *SimpleFinService) upsertAccount(id, name, currency string, balance, ava
 		// Create new
 		s.DB.Create(&database.AccountMap{
 			ExternalID:       id,
-			Provider:         "simplefin",
+			Provider:         s.Name(),
 			Name:             name,
-			LedgerAccount:    "Assets:FIXME:" + id,
+			LedgerAccount:    s.DefaultLedgerAccount(id),
 			Currency:         currency,
 			CurrentBalance:   balance,
 			AvailableBalance: available,
@@ -166,4 +317,9 @@ func (s *SimpleFinService) upsertAccount(id, name, currency string, balance, ava
 		acc.LastUpdated = time.Now()
 		s.DB.Save(&acc)
 	}
+
+	// Dated history for balance assertions in the export
+	if err := database.RecordBalance(s.DB, id, asOf.Format("2006-01-02"), balance, currency); err != nil {
+		fmt.Printf("[WARN] Could not record balance for %s: %v\n", id, err)
+	}
 }
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"expense_tracker/database"
+)
+
+// fakeBridge serves one account with a transaction per requested window. Windows starting
+// at or after failFrom get a 500.
+func fakeBridge(t *testing.T, failFrom time.Time, requests *[]time.Time) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.ParseInt(r.URL.Query().Get("start-date"), 10, 64)
+		end, _ := strconv.ParseInt(r.URL.Query().Get("end-date"), 10, 64)
+		*requests = append(*requests, time.Unix(start, 0))
+		if !failFrom.IsZero() && !time.Unix(start, 0).Before(failFrom) {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(SFResponse{Accounts: []SFAccount{{
+			ID: "acct1", Name: "Checking", Currency: "USD", Balance: "100.00",
+			Transactions: []SFTransaction{{ID: "t" + strconv.FormatInt(start, 10), Posted: end - 60, Amount: "-1.00", Description: "COFFEE"}},
+		}}})
+	}))
+}
+
+func TestSimpleFinSyncRangeKeepsWatermarkBeforeFailedWindow(t *testing.T) {
+	db := newTestDB(t)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 3*sfChunkDays)
+	secondWindow := from.AddDate(0, 0, sfChunkDays)
+
+	var requests []time.Time
+	srv := fakeBridge(t, secondWindow, &requests)
+	defer srv.Close()
+
+	sf := NewSimpleFinService(db, srv.URL, NewRuleEngine(db))
+	if err := sf.SyncRange(from, to); err == nil {
+		t.Fatal("SyncRange succeeded, want the second window's error")
+	}
+	if len(requests) != 2 || !requests[0].Equal(from) || !requests[1].Equal(secondWindow) {
+		t.Errorf("requested windows starting %v, want oldest first and none after the failure", requests)
+	}
+
+	var acc database.AccountMap
+	if err := db.First(&acc, "external_id = ?", "acct1").Error; err != nil {
+		t.Fatalf("account: %v", err)
+	}
+	if !acc.SyncedThrough.Equal(secondWindow) {
+		t.Errorf("SyncedThrough = %v, want %v (end of the last stored window)", acc.SyncedThrough, secondWindow)
+	}
+}
+
+func TestSimpleFinSyncRangeAdvancesWatermark(t *testing.T) {
+	db := newTestDB(t)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, sfChunkDays+10)
+
+	var requests []time.Time
+	srv := fakeBridge(t, time.Time{}, &requests)
+	defer srv.Close()
+
+	sf := NewSimpleFinService(db, srv.URL, NewRuleEngine(db))
+	if err := sf.SyncRange(from, to); err != nil {
+		t.Fatalf("SyncRange: %v", err)
+	}
+	if len(requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(requests))
+	}
+
+	var acc database.AccountMap
+	db.First(&acc, "external_id = ?", "acct1")
+	if !acc.SyncedThrough.Equal(to) {
+		t.Errorf("SyncedThrough = %v, want %v", acc.SyncedThrough, to)
+	}
+	var count int64
+	db.Model(&database.Transaction{}).Count(&count)
+	if count != 2 {
+		t.Errorf("stored %d transactions, want 2", count)
+	}
+
+	// A backfill of older history never moves the watermark back
+	if err := sf.SyncRange(from.AddDate(0, 0, -30), from); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	db.First(&acc, "external_id = ?", "acct1")
+	if !acc.SyncedThrough.Equal(to) {
+		t.Errorf("SyncedThrough after backfill = %v, want %v", acc.SyncedThrough, to)
+	}
+}
+
+// A closed account the bridge no longer returns must not pull every sync back to its watermark
+func TestSimpleFinSyncIgnoresAccountsNoLongerReturned(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	db.Create(&[]database.AccountMap{
+		{ExternalID: "acct1", Provider: "simplefin", Name: "Checking", SyncedThrough: now.AddDate(0, 0, -2)},
+		{ExternalID: "closed", Provider: "simplefin", Name: "Old Card", SyncedThrough: now.AddDate(0, 0, -200)},
+	})
+
+	var requests []time.Time
+	srv := fakeBridge(t, time.Time{}, &requests)
+	defer srv.Close()
+
+	sf := NewSimpleFinService(db, srv.URL, NewRuleEngine(db))
+	if err := sf.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	want := now.AddDate(0, 0, -2-sfOverlapDays).Unix()
+	if len(requests) != 1 || requests[0].Unix() != want {
+		t.Errorf("requested windows starting %v, want one from %v", requests, time.Unix(want, 0))
+	}
+
+	// When every account is behind (say the server was off for months), sync catches up
+	db.Model(&database.AccountMap{}).Where("external_id = ?", "acct1").Update("synced_through", now.AddDate(0, 0, -190))
+	requests = nil
+	if err := sf.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(requests) == 0 || requests[0].Unix() != now.AddDate(0, 0, -200-sfOverlapDays).Unix() {
+		t.Errorf("requested windows starting %v, want a catch-up from the oldest watermark", requests)
+	}
+}
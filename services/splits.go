@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidSplit = errors.New("invalid split")
+
+// SetSplits replaces a transaction's splits. An empty list removes them, leaving the
+// transaction with its single category again.
+// The largest split's category becomes the LedgerCategory so lists and suggestions stay sensible.
+func SetSplits(db *gorm.DB, txID string, splits []database.TransactionSplit) error {
+	var tx database.Transaction
+	if err := db.First(&tx, "id = ?", txID).Error; err != nil {
+		return fmt.Errorf("transaction %s: %w", txID, err)
+	}
+
+	if len(splits) == 1 {
+		return fmt.Errorf("%w: need at least two parts, or none to remove the split", ErrInvalidSplit)
+	}
+
+	var sum database.Money
+	largest := -1
+	for i := range splits {
+		s := &splits[i]
+		s.ID = 0
+		s.TransactionID = txID
+		s.Category = strings.TrimSpace(s.Category)
+		if s.Category == "" {
+			return fmt.Errorf("%w: part %d has no category", ErrInvalidSplit, i+1)
+		}
+		if s.Amount == 0 {
+			return fmt.Errorf("%w: part %d has no amount", ErrInvalidSplit, i+1)
+		}
+		sum += s.Amount
+		if largest < 0 || s.Amount.Abs() > splits[largest].Amount.Abs() {
+			largest = i
+		}
+	}
+	if len(splits) > 0 && sum != tx.Amount {
+		return fmt.Errorf("%w: parts add up to %s but the transaction is %s", ErrInvalidSplit, sum, tx.Amount)
+	}
+
+	return db.Transaction(func(dbtx *gorm.DB) error {
+		if err := dbtx.Where("transaction_id = ?", txID).Delete(&database.TransactionSplit{}).Error; err != nil {
+			return err
+		}
+		if len(splits) == 0 {
+			return nil
+		}
+		if err := dbtx.Create(&splits).Error; err != nil {
+			return err
+		}
+
+		tx.LedgerCategory = splits[largest].Category
+		tx.IsReviewed = true
+		tx.ClearSuggestion()
+		return dbtx.Save(&tx).Error
+	})
+}
+
+// dropStaleSplits removes tx's split when its parts no longer add up to tx.Amount (the source
+// changed the amount on a sync) and sends tx back to review. The caller saves tx.
+func dropStaleSplits(db *gorm.DB, tx *database.Transaction) error {
+	var splits []database.TransactionSplit
+	if err := db.Where("transaction_id = ?", tx.ID).Find(&splits).Error; err != nil {
+		return err
+	}
+	if len(splits) == 0 || splitsSum(splits) == tx.Amount {
+		return nil
+	}
+	if err := db.Where("transaction_id = ?", tx.ID).Delete(&database.TransactionSplit{}).Error; err != nil {
+		return err
+	}
+	fmt.Printf("[WARN] %s %s: amount changed to %s, split of %s removed for review\n", tx.Date, tx.Payee, tx.Amount, splitsSum(splits))
+	tx.IsReviewed = false
+	return nil
+}
+
+// balancedSplits returns tx's split parts, or nil if they don't add up to its amount so an
+// unbalanced entry is never exported; the row then exports with its single category
+func balancedSplits(tx database.Transaction, splits map[string][]database.TransactionSplit) []database.TransactionSplit {
+	parts := splits[tx.ID]
+	if len(parts) == 0 {
+		return nil
+	}
+	if splitsSum(parts) != tx.Amount {
+		fmt.Printf("[WARN] %s %s: split adds up to %s, not %s; exporting it unsplit\n", tx.Date, tx.Payee, splitsSum(parts), tx.Amount)
+		return nil
+	}
+	return parts
+}
+
+func splitsSum(splits []database.TransactionSplit) database.Money {
+	var sum database.Money
+	for _, s := range splits {
+		sum += s.Amount
+	}
+	return sum
+}
+
+// LoadSplits returns every transaction's splits keyed by transaction ID
+func LoadSplits(db *gorm.DB) (map[string][]database.TransactionSplit, error) {
+	var splits []database.TransactionSplit
+	if err := db.Order("id asc").Find(&splits).Error; err != nil {
+		return nil, err
+	}
+	byTx := make(map[string][]database.TransactionSplit)
+	for _, s := range splits {
+		byTx[s.TransactionID] = append(byTx[s.TransactionID], s)
+	}
+	return byTx, nil
+}
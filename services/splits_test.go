@@ -0,0 +1,109 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestSetSplitsValidation(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&database.Transaction{ID: "t", Amount: -1000, LedgerCategory: uncategorized})
+
+	tests := []struct {
+		name   string
+		splits []database.TransactionSplit
+	}{
+		{"single part", []database.TransactionSplit{{Category: "Expenses:Food", Amount: -1000}}},
+		{"no category", []database.TransactionSplit{{Category: " ", Amount: -500}, {Category: "Expenses:Home", Amount: -500}}},
+		{"zero part", []database.TransactionSplit{{Category: "Expenses:Food", Amount: -1000}, {Category: "Expenses:Home", Amount: 0}}},
+		{"wrong total", []database.TransactionSplit{{Category: "Expenses:Food", Amount: -600}, {Category: "Expenses:Home", Amount: -500}}},
+	}
+	for _, tt := range tests {
+		if err := SetSplits(db, "t", tt.splits); !errors.Is(err, ErrInvalidSplit) {
+			t.Errorf("%s: err = %v, want ErrInvalidSplit", tt.name, err)
+		}
+	}
+
+	err := SetSplits(db, "t", []database.TransactionSplit{{Category: "Expenses:Food", Amount: -400}, {Category: "Expenses:Home", Amount: -600}})
+	if err != nil {
+		t.Fatalf("SetSplits: %v", err)
+	}
+	var tx database.Transaction
+	db.First(&tx, "id = ?", "t")
+	if tx.LedgerCategory != "Expenses:Home" || !tx.IsReviewed {
+		t.Errorf("after SetSplits: category %q, reviewed %v; want the largest part and reviewed", tx.LedgerCategory, tx.IsReviewed)
+	}
+}
+
+// A bank correcting the amount must not leave a split that no longer adds up
+func TestSyncAmountChangeDropsSplit(t *testing.T) {
+	db := newTestDB(t)
+	importer := NewOFXImportService(db, NewRuleEngine(db))
+	if _, err := importer.Import(strings.NewReader(xmlStatement)); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	const id = "ofx_4111222233334444_X1"
+	err := SetSplits(db, id, []database.TransactionSplit{{Category: "Expenses:Streaming", Amount: -500}, {Category: "Expenses:Gifts", Amount: -499}})
+	if err != nil {
+		t.Fatalf("SetSplits: %v", err)
+	}
+
+	// Same amount again: the split stays
+	importer.Import(strings.NewReader(xmlStatement))
+	var count int64
+	db.Model(&database.TransactionSplit{}).Where("transaction_id = ?", id).Count(&count)
+	if count != 2 {
+		t.Fatalf("split has %d parts after an unchanged re-import, want 2", count)
+	}
+
+	corrected := strings.Replace(xmlStatement, "<TRNAMT>-9.99</TRNAMT>", "<TRNAMT>-12.00</TRNAMT>", 1)
+	if _, err := importer.Import(strings.NewReader(corrected)); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	db.Model(&database.TransactionSplit{}).Where("transaction_id = ?", id).Count(&count)
+	var tx database.Transaction
+	db.First(&tx, "id = ?", id)
+	if count != 0 || tx.IsReviewed || tx.Amount != -1200 {
+		t.Errorf("after amount change: %d split parts, reviewed %v, amount %s; want split removed and back in review", count, tx.IsReviewed, tx.Amount)
+	}
+}
+
+func TestExportSkipsUnbalancedSplit(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&database.AccountMap{ExternalID: "chk", Provider: "simplefin", LedgerAccount: "Assets:Checking"})
+	db.Create(&[]database.Transaction{
+		{ID: "ok", Provider: "simplefin", AccountID: "chk", Date: "2024-03-01", Payee: "COSTCO", Amount: -1000, Currency: "USD", LedgerCategory: "Expenses:Groceries"},
+		{ID: "stale", Provider: "simplefin", AccountID: "chk", Date: "2024-03-02", Payee: "TARGET", Amount: -1200, Currency: "USD", LedgerCategory: "Expenses:Home"},
+	})
+	// Written directly, the way a stale split would look after an amount change
+	db.Create(&[]database.TransactionSplit{
+		{TransactionID: "ok", Category: "Expenses:Groceries", Amount: -700},
+		{TransactionID: "ok", Category: "Expenses:Home", Amount: -300},
+		{TransactionID: "stale", Category: "Expenses:Home", Amount: -600},
+		{TransactionID: "stale", Category: "Expenses:Gifts", Amount: -400},
+	})
+
+	exporter := newTestExporter(t, db, FormatLedger)
+	buckets, err := exporter.entriesByMonth()
+	if err != nil {
+		t.Fatalf("entriesByMonth: %v", err)
+	}
+	entries := allEntries(buckets)
+	if got := entries["ok"].Postings; len(got) != 2 {
+		t.Errorf("balanced split postings = %+v, want 2", got)
+	}
+	if got := entries["stale"].Postings; len(got) != 1 || got[0].Account != "Expenses:Home" || got[0].Amount != 1200 {
+		t.Errorf("unbalanced split postings = %+v, want one 12.00 posting to Expenses:Home", got)
+	}
+
+	records, err := exporter.Records(ExportFilter{})
+	if err != nil {
+		t.Fatalf("Records: %v", err)
+	}
+	if len(records) != 3 {
+		t.Errorf("got %d records, want 2 parts plus the unsplit row", len(records))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
@@ -12,21 +13,58 @@ import (
 	"gorm.io/gorm"
 )
 
+// SplitwiseLedgerAccount is where our share of group expenses accumulates
+const SplitwiseLedgerAccount = "Liabilities:Payable:Splitwise"
+
+const (
+	splitwiseAPI       = "https://secure.splitwise.com/api/v3.0"
+	splitwiseAccountID = "splitwise_group"
+	swPageSize         = 100
+	// Splitwise timestamps are set server-side, so allow for clock skew around the cursor
+	swCursorOverlap = 10 * time.Minute
+)
+
 type SplitwiseService struct {
-	DB     *gorm.DB
-	APIKey string
-	UserID int
-	Rules  *RuleEngine
+	DB      *gorm.DB
+	APIKey  string
+	BaseURL string
+	UserID  int
+	Rules   *RuleEngine
 }
 
 func NewSplitwiseService(db *gorm.DB, apiKey string, rules *RuleEngine) *SplitwiseService {
 	return &SplitwiseService{
-		DB:     db,
-		APIKey: apiKey,
-		Rules:  rules,
+		DB:      db,
+		APIKey:  apiKey,
+		BaseURL: splitwiseAPI,
+		Rules:   rules,
 	}
 }
 
+func (s *SplitwiseService) Name() string { return "splitwise" }
+
+func (s *SplitwiseService) Labels() []string {
+	return []string{"splitwise", "splitwise_payer", "splitwise_payment"}
+}
+
+func (s *SplitwiseService) DefaultLedgerAccount(externalID string) string {
+	return SplitwiseLedgerAccount
+}
+
+func (s *SplitwiseService) EnsureAccount(externalID, name, currency string) {
+	ensureProviderAccount(s.DB, s, externalID, name, currency)
+}
+
+func (s *SplitwiseService) ExportHint(tx database.Transaction) ExportHint {
+	switch tx.Provider {
+	case "splitwise":
+		return ExportHint{SourceAccount: SplitwiseLedgerAccount}
+	case "splitwise_payer":
+		return ExportHint{Skip: true} // Skip reimbursement records to avoid duplicates
+	}
+	return ExportHint{}
+}
+
 type SWUserResp struct {
 	User struct {
 		ID int `json:"id"`
@@ -59,7 +97,7 @@ func (s *SplitwiseService) GetMyID() error {
 	if s.UserID != 0 {
 		return nil
 	}
-	req, _ := http.NewRequest("GET", "https://secure.splitwise.com/api/v3.0/get_current_user", nil)
+	req, _ := http.NewRequest("GET", s.BaseURL+"/get_current_user", nil)
 	req.Header.Add("Authorization", "Bearer "+s.APIKey)
 
 	resp, err := http.DefaultClient.Do(req)
@@ -81,150 +119,258 @@ func (s *SplitwiseService) GetMyID() error {
 	return nil
 }
 
+// Sync pages through every expense on the first run, then only those updated since the stored cursor
 func (s *SplitwiseService) Sync() error {
 	if s.APIKey == "" {
 		return nil
 	}
 
-	s.ensureAccountExists("splitwise_group", "Splitwise Shared Expenses")
+	s.EnsureAccount(splitwiseAccountID, "Splitwise Shared Expenses", "")
 
 	if err := s.GetMyID(); err != nil {
 		return err
 	}
 
-	// Fetch recent expenses (limit 50 is usually enough for daily syncs)
-	req, _ := http.NewRequest("GET", "https://secure.splitwise.com/api/v3.0/get_expenses?limit=50", nil)
-	req.Header.Add("Authorization", "Bearer "+s.APIKey)
+	var account database.AccountMap
+	if err := s.DB.First(&account, "external_id = ?", splitwiseAccountID).Error; err != nil {
+		return err
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// Taken before fetching so anything edited mid-sync is caught next time
+	startedAt := time.Now().UTC()
+
+	params := url.Values{}
+	if !account.SyncedThrough.IsZero() {
+		cursor := account.SyncedThrough.Add(-swCursorOverlap)
+		params.Set("updated_after", cursor.UTC().Format(time.RFC3339))
+	}
+
+	// A failed page or write leaves the cursor alone, so the next sync fetches those expenses again
+	count, err := s.syncPages(params)
+	if count > 0 {
+		fmt.Printf("[INFO] Synced %d new Splitwise items\n", count)
+	}
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	var data SWExpensesResp
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	account.SyncedThrough = startedAt
+	return s.DB.Save(&account).Error
+}
+
+// Backfill re-reads every expense dated on or after from
+func (s *SplitwiseService) Backfill(from time.Time) error {
+	if s.APIKey == "" {
+		return nil
+	}
+
+	s.EnsureAccount(splitwiseAccountID, "Splitwise Shared Expenses", "")
+
+	if err := s.GetMyID(); err != nil {
 		return err
 	}
 
+	params := url.Values{}
+	params.Set("dated_after", from.UTC().Format(time.RFC3339))
+
+	count, err := s.syncPages(params)
+	if count > 0 {
+		fmt.Printf("[INFO] Backfilled %d new Splitwise items\n", count)
+	}
+	return err
+}
+
+// syncPages walks get_expenses with limit/offset until an empty page comes back. It stops
+// at the first expense that can't be stored.
+func (s *SplitwiseService) syncPages(params url.Values) (int, error) {
 	count := 0
-	for _, exp := range data.Expenses {
-		if exp.DeletedAt != nil {
-			continue
+	for offset := 0; ; offset += swPageSize {
+		params.Set("limit", strconv.Itoa(swPageSize))
+		params.Set("offset", strconv.Itoa(offset))
+
+		expenses, err := s.fetchExpenses(params)
+		if err != nil {
+			return count, err
+		}
+		if len(expenses) == 0 {
+			return count, nil
 		}
 
-		// --- NEW LOGIC: Handle Payments vs Expenses ---
-		var myAmount float64
-		var didIPay bool
-		involved := false
-
-		for _, u := range exp.Users {
-			if u.UserID == s.UserID {
-				owed, _ := strconv.ParseFloat(u.OwedShare, 64)
-				paid, _ := strconv.ParseFloat(u.PaidShare, 64)
-
-				if exp.Payment {
-					// Settlement Logic
-					if paid > 0 {
-						// I Paid (Settling debt) -> Positive Amount (reduces liability)
-						myAmount = paid
-						involved = true
-					} else if owed > 0 {
-						// I Received (Others settling debt to me) -> Negative Amount (reduces asset)
-						myAmount = -owed
-						involved = true
-					}
-				} else {
-					// Expense Logic
-					if owed > 0 {
-						// I owe money -> Negative (increases liability)
-						myAmount = -owed
-						involved = true
-					}
-					if paid > 0 {
-						didIPay = true
-					}
-				}
+		for _, exp := range expenses {
+			created, err := s.processExpense(exp)
+			if err != nil {
+				return count, fmt.Errorf("splitwise expense %d: %w", exp.ID, err)
+			}
+			if created {
+				count++
 			}
 		}
+	}
+}
 
-		// Skip if I'm not involved or the amount is effectively 0
-		if !involved || myAmount == 0 {
-			continue
-		}
+func (s *SplitwiseService) fetchExpenses(params url.Values) ([]SWExpense, error) {
+	req, _ := http.NewRequest("GET", s.BaseURL+"/get_expenses?"+params.Encode(), nil)
+	req.Header.Add("Authorization", "Bearer "+s.APIKey)
 
-		parsedTime, _ := time.Parse(time.RFC3339, exp.Date)
-		dateStr := parsedTime.Format("2006-01-02")
-		txID := fmt.Sprintf("sw_%d", exp.ID)
-
-		providerLabel := "splitwise"
-		// If I paid for a group expense (reimbursement), mark it special
-		// But if it's a direct payment (settlement), keep it standard "splitwise" so it shows up in main lists easily
-		if didIPay && !exp.Payment {
-			providerLabel = "splitwise_payer"
-		} else if exp.Payment {
-			providerLabel = "splitwise_payment"
-		}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
-		// Check existence
-		var existing database.Transaction
-		result := s.DB.Limit(1).Find(&existing, "id = ?", txID)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("splitwise get_expenses error: %d", resp.StatusCode)
+	}
 
-		if result.RowsAffected == 0 {
-			// Determine Category
-			cat := "Expenses:Uncategorized"
+	var data SWExpensesResp
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return data.Expenses, nil
+}
+
+// processExpense upserts our share of one expense. Returns true if a new transaction was created.
+func (s *SplitwiseService) processExpense(exp SWExpense) (bool, error) {
+	txID := fmt.Sprintf("sw_%d", exp.ID)
+
+	if exp.DeletedAt != nil {
+		return false, s.voidTransaction(txID, "Deleted in Splitwise on "+(*exp.DeletedAt)[:min(10, len(*exp.DeletedAt))])
+	}
+
+	// --- NEW LOGIC: Handle Payments vs Expenses ---
+	var myAmount database.Money
+	var didIPay bool
+	involved := false
+
+	for _, u := range exp.Users {
+		if u.UserID == s.UserID {
+			owed, _ := database.ParseMoney(u.OwedShare)
+			paid, _ := database.ParseMoney(u.PaidShare)
 
 			if exp.Payment {
-				// Force settlements to Transfer category
-				cat = "Transfers:Splitwise"
+				// Settlement Logic
+				if paid > 0 {
+					// I Paid (Settling debt) -> Positive Amount (reduces liability)
+					myAmount = paid
+					involved = true
+				} else if owed > 0 {
+					// I Received (Others settling debt to me) -> Negative Amount (reduces asset)
+					myAmount = -owed
+					involved = true
+				}
 			} else {
-				// Run Auto-Rules for normal expenses
-				if match := s.Rules.Apply(exp.Description); match != "" {
-					cat = match
+				// Expense Logic
+				if owed > 0 {
+					// I owe money -> Negative (increases liability)
+					myAmount = -owed
+					involved = true
+				}
+				if paid > 0 {
+					didIPay = true
 				}
 			}
+		}
+	}
 
-			tx := database.Transaction{
-				ID:             txID,
-				Provider:       providerLabel,
-				AccountID:      "splitwise_group",
-				Date:           dateStr,
-				Payee:          exp.Description,
-				Amount:         myAmount,
-				Currency:       exp.Currency,
-				LedgerCategory: cat,
-				Notes:          "Sync Import",
-				IsReviewed:     exp.Payment, // Auto-mark payments as reviewed since we know they are transfers
-			}
-			s.DB.Create(&tx)
-			count++
+	// Skip if I'm not involved or the amount is effectively 0
+	if !involved || myAmount == 0 {
+		// Someone may have edited us out of an expense we already imported
+		return false, s.voidTransaction(txID, "No longer involved in Splitwise expense")
+	}
+
+	parsedTime, _ := time.Parse(time.RFC3339, exp.Date)
+	dateStr := parsedTime.Format("2006-01-02")
+
+	providerLabel := "splitwise"
+	// If I paid for a group expense (reimbursement), mark it special
+	// But if it's a direct payment (settlement), keep it standard "splitwise" so it shows up in main lists easily
+	if didIPay && !exp.Payment {
+		providerLabel = "splitwise_payer"
+	} else if exp.Payment {
+		providerLabel = "splitwise_payment"
+	}
+
+	// Check existence
+	var existing database.Transaction
+	result := s.DB.Limit(1).Find(&existing, "id = ?", txID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		tx := database.Transaction{
+			ID:             txID,
+			Provider:       providerLabel,
+			AccountID:      splitwiseAccountID,
+			Date:           dateStr,
+			Payee:          exp.Description,
+			RawPayee:       exp.Description,
+			Amount:         myAmount,
+			Currency:       exp.Currency,
+			LedgerCategory: "Expenses:Uncategorized",
+			Notes:          "Sync Import",
+			IsReviewed:     exp.Payment, // Auto-mark payments as reviewed since we know they are transfers
+		}
+
+		if exp.Payment {
+			// Force settlements to Transfer category
+			tx.LedgerCategory = "Transfers:Splitwise"
 		} else {
-			// Update existing (e.g. if amount changed in Splitwise)
-			// We generally trust Splitwise updates
-			existing.Amount = myAmount
-			existing.Date = dateStr
-			if !existing.IsReviewed {
-				existing.Payee = exp.Description
+			// Run Auto-Rules for normal expenses
+			s.Rules.Categorize(&tx)
+		}
+		if err := s.DB.Create(&tx).Error; err != nil {
+			return false, err
+		}
+		return true, nil
+	} else {
+		// Update existing (e.g. if amount changed in Splitwise)
+		// We generally trust Splitwise updates
+		stored := existing
+		existing.Amount = myAmount
+		existing.Date = dateStr
+		existing.RawPayee = exp.Description
+		if !existing.IsReviewed {
+			existing.Payee = exp.Description
+			if !exp.Payment {
+				s.Rules.Recategorize(&existing, stored)
+			}
+		}
+		if existing.Amount != stored.Amount {
+			if err := dropStaleSplits(s.DB, &existing); err != nil {
+				return false, err
 			}
-			s.DB.Save(&existing)
+		}
+		// Restored in Splitwise after we voided it (merged duplicates stay voided)
+		if existing.DuplicateOf == "" {
+			existing.IsVoided = false
+			existing.VoidReason = ""
+		}
+		if err := s.DB.Save(&existing).Error; err != nil {
+			return false, err
 		}
 	}
+	return false, nil
+}
 
-	if count > 0 {
-		fmt.Printf("[INFO] Synced %d new Splitwise items\n", count)
+// voidTransaction keeps the local row for audit but takes it out of the journal.
+// Voiding clears IsReviewed so the row shows up in the review queue.
+func (s *SplitwiseService) voidTransaction(txID, reason string) error {
+	var existing database.Transaction
+	result := s.DB.Limit(1).Find(&existing, "id = ?", txID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 || existing.IsVoided {
+		return nil
 	}
-	return nil
-}
 
-func (s *SplitwiseService) ensureAccountExists(id, name string) {
-	var count int64
-	s.DB.Model(&database.AccountMap{}).Where("external_id = ?", id).Count(&count)
-	if count == 0 {
-		s.DB.Create(&database.AccountMap{
-			ExternalID:    id,
-			Provider:      "splitwise",
-			Name:          name,
-			LedgerAccount: "Liabilities:Payable:Splitwise",
-		})
+	existing.IsVoided = true
+	existing.VoidReason = reason
+	existing.IsReviewed = false
+	if err := s.DB.Save(&existing).Error; err != nil {
+		return err
 	}
+	fmt.Printf("[INFO] Voided %s (%s): %s\n", txID, existing.Payee, reason)
+	return nil
 }
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+const swTestUser = 7
+
+// fakeSplitwise pages through *expenses by limit/offset and records every get_expenses query
+func fakeSplitwise(t *testing.T, expenses *[]SWExpense, queries *[]url.Values) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/get_current_user":
+			var resp SWUserResp
+			resp.User.ID = swTestUser
+			json.NewEncoder(w).Encode(resp)
+		case "/get_expenses":
+			q := r.URL.Query()
+			*queries = append(*queries, q)
+			offset, _ := strconv.Atoi(q.Get("offset"))
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			page := []SWExpense{}
+			if offset < len(*expenses) {
+				page = (*expenses)[offset:min(offset+limit, len(*expenses))]
+			}
+			json.NewEncoder(w).Encode(SWExpensesResp{Expenses: page})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+// swExpense is a dinner someone else paid for, where we owe owed
+func swExpense(id int, owed string) SWExpense {
+	return SWExpense{
+		ID: id, Date: "2024-03-10T19:00:00Z", Description: fmt.Sprintf("DINNER %d", id), Cost: "100.00", Currency: "USD",
+		Users: []SWUser{{UserID: swTestUser, PaidShare: "0.00", OwedShare: owed}, {UserID: 8, PaidShare: "100.00", OwedShare: "50.00"}},
+	}
+}
+
+func newTestSplitwise(t *testing.T, db *gorm.DB, srv *httptest.Server) *SplitwiseService {
+	t.Helper()
+	sw := NewSplitwiseService(db, "key", NewRuleEngine(db))
+	sw.BaseURL = srv.URL
+	return sw
+}
+
+func splitwiseCursor(t *testing.T, db *gorm.DB) database.AccountMap {
+	t.Helper()
+	var acc database.AccountMap
+	if err := db.First(&acc, "external_id = ?", splitwiseAccountID).Error; err != nil {
+		t.Fatalf("splitwise account: %v", err)
+	}
+	return acc
+}
+
+func TestSplitwiseSyncPagesAndCursor(t *testing.T) {
+	db := newTestDB(t)
+	var expenses []SWExpense
+	for id := 1; id <= swPageSize+50; id++ {
+		expenses = append(expenses, swExpense(id, "50.00"))
+	}
+	var queries []url.Values
+	srv := fakeSplitwise(t, &expenses, &queries)
+	defer srv.Close()
+	sw := newTestSplitwise(t, db, srv)
+
+	if err := sw.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	var offsets []string
+	for _, q := range queries {
+		offsets = append(offsets, q.Get("offset"))
+		if q.Has("updated_after") {
+			t.Errorf("first sync sent updated_after=%s, want a full fetch", q.Get("updated_after"))
+		}
+	}
+	if fmt.Sprint(offsets) != "[0 100 200]" {
+		t.Errorf("requested offsets %v, want [0 100 200]", offsets)
+	}
+	var count int64
+	db.Model(&database.Transaction{}).Where("provider = ?", "splitwise").Count(&count)
+	if count != int64(len(expenses)) {
+		t.Errorf("stored %d expenses, want %d", count, len(expenses))
+	}
+	synced := splitwiseCursor(t, db).SyncedThrough
+	if synced.IsZero() {
+		t.Fatal("cursor not set after a clean sync")
+	}
+
+	// Next time only changes are fetched: one deletion and one edit
+	deletedAt := "2024-03-12T08:00:00Z"
+	deleted := swExpense(3, "50.00")
+	deleted.DeletedAt = &deletedAt
+	expenses = []SWExpense{deleted, swExpense(4, "30.00")}
+	queries = nil
+	if err := sw.Sync(); err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+	if want := synced.Add(-swCursorOverlap).UTC().Format("2006-01-02T15:04:05Z07:00"); len(queries) == 0 || queries[0].Get("updated_after") != want {
+		t.Errorf("updated_after = %q, want %q", queries[0].Get("updated_after"), want)
+	}
+
+	var tx database.Transaction
+	db.First(&tx, "id = ?", "sw_3")
+	if !tx.IsVoided || tx.VoidReason != "Deleted in Splitwise on 2024-03-12" {
+		t.Errorf("deleted expense: voided %v, reason %q", tx.IsVoided, tx.VoidReason)
+	}
+	var edited database.Transaction
+	db.First(&edited, "id = ?", "sw_4")
+	if edited.Amount != -3000 {
+		t.Errorf("edited expense amount = %s, want -30.00", edited.Amount)
+	}
+}
+
+// Being edited out of an expense voids it; being added back restores it
+func TestSplitwiseVoidsExpensesWeLeft(t *testing.T) {
+	db := newTestDB(t)
+	expenses := []SWExpense{swExpense(1, "50.00")}
+	var queries []url.Values
+	srv := fakeSplitwise(t, &expenses, &queries)
+	defer srv.Close()
+	sw := newTestSplitwise(t, db, srv)
+
+	if err := sw.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	expenses = []SWExpense{swExpense(1, "0.00")}
+	if err := sw.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	var tx database.Transaction
+	db.First(&tx, "id = ?", "sw_1")
+	if !tx.IsVoided || tx.VoidReason != "No longer involved in Splitwise expense" {
+		t.Errorf("after leaving: voided %v, reason %q", tx.IsVoided, tx.VoidReason)
+	}
+
+	expenses = []SWExpense{swExpense(1, "25.00")}
+	if err := sw.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	db.First(&tx, "id = ?", "sw_1")
+	if tx.IsVoided || tx.VoidReason != "" || tx.Amount != -2500 {
+		t.Errorf("after rejoining: voided %v, reason %q, amount %s", tx.IsVoided, tx.VoidReason, tx.Amount)
+	}
+}
+
+func TestSplitwiseWriteFailureKeepsCursor(t *testing.T) {
+	db := newTestDB(t)
+	expenses := []SWExpense{swExpense(1, "10.00"), swExpense(2, "20.00"), swExpense(3, "30.00")}
+	var queries []url.Values
+	srv := fakeSplitwise(t, &expenses, &queries)
+	defer srv.Close()
+	sw := newTestSplitwise(t, db, srv)
+
+	failing := true
+	db.Callback().Create().Before("gorm:create").Register("fail_sw_2", func(tx *gorm.DB) {
+		if t, ok := tx.Statement.Dest.(*database.Transaction); ok && t.ID == "sw_2" && failing {
+			tx.AddError(errors.New("disk full"))
+		}
+	})
+
+	if err := sw.Sync(); err == nil {
+		t.Fatal("Sync succeeded, want the write error")
+	}
+	if !splitwiseCursor(t, db).SyncedThrough.IsZero() {
+		t.Error("cursor advanced past an expense that was never stored")
+	}
+	var count int64
+	db.Model(&database.Transaction{}).Where("id = ?", "sw_3").Count(&count)
+	if count != 0 {
+		t.Error("kept processing after the failed write")
+	}
+
+	failing = false
+	queries = nil
+	if err := sw.Sync(); err != nil {
+		t.Fatalf("retry Sync: %v", err)
+	}
+	if queries[0].Has("updated_after") {
+		t.Error("retry only asked for recent changes")
+	}
+	db.Model(&database.Transaction{}).Where("id IN ?", []string{"sw_1", "sw_2", "sw_3"}).Count(&count)
+	if count != 3 {
+		t.Errorf("stored %d of 3 expenses after the retry", count)
+	}
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"expense_tracker/database"
+)
+
+// ExportFilter narrows a table export. Empty fields don't filter.
+type ExportFilter struct {
+	From      string // YYYY-MM-DD, inclusive
+	To        string // YYYY-MM-DD, inclusive
+	AccountID string // AccountMap.ExternalID
+}
+
+// ExportRecord is one row of the CSV/JSON export. Split transactions produce one row per
+// part (same ID), so amounts always add up by category.
+type ExportRecord struct {
+	ID        string         `json:"id"`
+	Date      string         `json:"date"`
+	Payee     string         `json:"payee"`
+	Amount    database.Money `json:"amount"` // Account's point of view: negative is money out
+	Currency  string         `json:"currency"`
+	AccountID string         `json:"account_id"`
+	Account   string         `json:"account"` // Resolved ledger account
+	Category  string         `json:"category"`
+	Provider  string         `json:"provider"`
+	Notes     string         `json:"notes"`
+	Tags      []string       `json:"tags"`
+	Reviewed  bool           `json:"reviewed"`
+}
+
+var exportCSVHeader = []string{"id", "date", "payee", "amount", "currency", "account_id", "account", "category", "provider", "notes", "tags", "reviewed"}
+
+// hledger picks up transactions.csv.rules automatically, so `hledger -f transactions.csv` just works
+const exportCSVRules = `# hledger rules for transactions.csv (written by Expense Tracker)
+# hledger -f transactions.csv bal
+skip 1
+fields code, date, description, amount, currency, account_id, account, category, provider, notes, tags, reviewed
+date-format %Y-%m-%d
+account1 %account
+account2 %category
+comment %notes
+
+if %reviewed true
+  status *
+`
+
+func (f ExportFilter) Validate() error {
+	for _, d := range []string{f.From, f.To} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", d)
+		}
+	}
+	return nil
+}
+
+// Records returns the live (non-voided) transactions matching f, oldest first.
+// Accounts resolve the same way as in the journal, and transactions a provider keeps
+// out of the journal are left out here too.
+func (s *LedgerExportService) Records(f ExportFilter) ([]ExportRecord, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := s.DB.Where("is_voided = ?", false)
+	if f.From != "" {
+		query = query.Where("date >= ?", f.From)
+	}
+	if f.To != "" {
+		query = query.Where("date <= ?", f.To)
+	}
+	if f.AccountID != "" {
+		query = query.Where("account_id = ?", f.AccountID)
+	}
+	var txs []database.Transaction
+	if err := query.Order("date asc, id asc").Find(&txs).Error; err != nil {
+		return nil, err
+	}
+
+	splits, err := LoadSplits(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	records := []ExportRecord{}
+	for _, tx := range txs {
+		account, skip := s.ledgerAccount(tx)
+		if skip {
+			continue
+		}
+		rec := ExportRecord{
+			ID:        tx.ID,
+			Date:      tx.Date,
+			Payee:     tx.Payee,
+			Amount:    tx.Amount,
+			Currency:  tx.Currency,
+			AccountID: tx.AccountID,
+			Account:   account,
+			Category:  tx.LedgerCategory,
+			Provider:  tx.Provider,
+			Notes:     tx.Notes,
+			Tags:      tx.Tags,
+			Reviewed:  tx.IsReviewed,
+		}
+		if rec.Tags == nil {
+			rec.Tags = []string{}
+		}
+
+		parts := balancedSplits(tx, splits)
+		if len(parts) == 0 {
+			records = append(records, rec)
+			continue
+		}
+		for _, part := range parts {
+			partRec := rec
+			partRec.Amount = part.Amount
+			partRec.Category = part.Category
+			if part.Note != "" {
+				partRec.Notes = strings.TrimPrefix(rec.Notes+"; "+part.Note, "; ")
+			}
+			records = append(records, partRec)
+		}
+	}
+	return records, nil
+}
+
+func WriteRecordsCSV(w io.Writer, records []ExportRecord) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exportCSVHeader)
+	for _, r := range records {
+		cw.Write([]string{
+			r.ID, r.Date, r.Payee, r.Amount.String(), r.Currency, r.AccountID, r.Account,
+			r.Category, r.Provider, r.Notes, strings.Join(r.Tags, ", "), strconv.FormatBool(r.Reviewed),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func WriteRecordsJSON(w io.Writer, records []ExportRecord) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// ExportTables writes transactions.csv (plus hledger rules for it) and transactions.json
+// to RootDir, covering every live transaction
+func (s *LedgerExportService) ExportTables() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.Records(ExportFilter{})
+	if err != nil {
+		return err
+	}
+
+	run := s.newRun()
+	writers := map[string]func(io.Writer, []ExportRecord) error{
+		"transactions.csv":  WriteRecordsCSV,
+		"transactions.json": WriteRecordsJSON,
+	}
+	for name, write := range writers {
+		var buf bytes.Buffer
+		if err := write(&buf, records); err != nil {
+			return err
+		}
+		if err := run.write(name, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := run.write("transactions.csv.rules", []byte(exportCSVRules)); err != nil {
+		return err
+	}
+	if s.Git {
+		if err := s.commitExport(run, nil); err != nil {
+			fmt.Printf("[WARN] Export git commit failed: %v\n", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidTag = errors.New("invalid tag")
+
+// Tags are plain ("reimbursable") or name:value ("trip:japan2025"). They export as
+// ledger/hledger tag comments, so names can't hold spaces, colons or commas and values
+// can't hold commas (hledger ends a tag value at the next comma).
+
+// NormalizeTags trims tags, drops empty and repeated ones, and rejects anything that
+// wouldn't survive the round trip through a journal comment
+func NormalizeTags(tags []string) ([]string, error) {
+	var out []string
+	for _, raw := range tags {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(raw), ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name == "" {
+			if hasValue || value != "" {
+				return nil, fmt.Errorf("%w: %q has no name", ErrInvalidTag, raw)
+			}
+			continue
+		}
+		if strings.ContainsAny(name, " \t,;") {
+			return nil, fmt.Errorf("%w: name %q can't contain spaces, commas or semicolons", ErrInvalidTag, name)
+		}
+		if strings.ContainsAny(value, ",\n\r") {
+			return nil, fmt.Errorf("%w: value %q can't contain commas", ErrInvalidTag, value)
+		}
+
+		tag := name
+		if value != "" {
+			tag += ":" + value
+		}
+		out = AddTags(out, []string{tag})
+	}
+	return out, nil
+}
+
+// AddTags merges add into tags. A name:value tag replaces any existing value for the same
+// name, so re-tagging a transaction with trip:korea moves it off trip:japan2025.
+func AddTags(tags, add []string) []string {
+	for _, tag := range add {
+		name, _ := TagParts(tag)
+		replaced := false
+		for i, existing := range tags {
+			if existingName, _ := TagParts(existing); existingName == name {
+				tags[i] = tag
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// TagParts splits "trip:japan2025" into ("trip", "japan2025"); plain tags have no value
+func TagParts(tag string) (name, value string) {
+	name, value, _ = strings.Cut(tag, ":")
+	return name, value
+}
+
+// ledgerTag renders a tag the way hledger reads it: "trip:japan2025", or "reimbursable:"
+func ledgerTag(tag string) string {
+	name, value := TagParts(tag)
+	return name + ":" + value
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"expense_tracker/database"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TransferSuggested = "suggested"
+	TransferConfirmed = "confirmed"
+	TransferRejected  = "rejected"
+
+	// Allow for posting delays (paid on Friday, posted on Monday)
+	transferDayTolerance = 4
+)
+
+// Payees that look like money movement; used to break ties between same-amount candidates
+var transferPayeeRe = regexp.MustCompile(`(?i)transfer|xfer|payment|pmt|venmo|zelle|splitwise|autopay|ach`)
+
+// TransferMatcher finds pairs of transactions that are two sides of one transfer
+type TransferMatcher struct {
+	DB *gorm.DB
+}
+
+func NewTransferMatcher(db *gorm.DB) *TransferMatcher {
+	return &TransferMatcher{DB: db}
+}
+
+// Run suggests links for unlinked transactions with opposite amounts in different accounts
+// within transferDayTolerance days. Ambiguous matches are left alone.
+func (m *TransferMatcher) Run() (int, error) {
+	var links []database.TransferLink
+	if err := m.DB.Find(&links).Error; err != nil {
+		return 0, err
+	}
+
+	linked := make(map[string]bool)   // Legs already in a live link
+	rejected := make(map[string]bool) // "out|in" pairs the user turned down
+	for _, l := range links {
+		if l.Status == TransferRejected {
+			rejected[l.OutID+"|"+l.InID] = true
+			continue
+		}
+		linked[l.OutID] = true
+		linked[l.InID] = true
+	}
+
+	var txs []database.Transaction
+	// Splitwise expense shares aren't transfers; only settlements ("splitwise_payment") are
+	err := m.DB.Where("is_voided = ? AND provider NOT IN ?", false, []string{"splitwise", "splitwise_payer"}).
+		Order("date asc").Find(&txs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var accounts []database.AccountMap
+	m.DB.Find(&accounts)
+	ledgerAccounts := make(map[string]string)
+	for _, a := range accounts {
+		ledgerAccounts[a.ExternalID] = a.LedgerAccount
+	}
+
+	// Index inflows by amount so each outflow only looks at plausible partners
+	inflows := make(map[database.Money][]*database.Transaction)
+	for i := range txs {
+		tx := &txs[i]
+		if tx.Amount > 0 && !linked[tx.ID] {
+			inflows[tx.Amount] = append(inflows[tx.Amount], tx)
+		}
+	}
+
+	count := 0
+	for i := range txs {
+		out := &txs[i]
+		if out.Amount >= 0 || linked[out.ID] {
+			continue
+		}
+		outDate, err := time.Parse("2006-01-02", out.Date)
+		if err != nil {
+			continue
+		}
+
+		var candidates []*database.Transaction
+		for _, in := range inflows[out.Amount.Neg()] {
+			if linked[in.ID] || in.AccountID == out.AccountID || in.Currency != out.Currency {
+				continue
+			}
+			if rejected[out.ID+"|"+in.ID] {
+				continue
+			}
+			// Two Splitwise settlements can't settle each other
+			if in.Provider == "splitwise_payment" && out.Provider == "splitwise_payment" {
+				continue
+			}
+			if abs(dayGap(outDate, in.Date)) > transferDayTolerance {
+				continue
+			}
+			candidates = append(candidates, in)
+		}
+
+		in := pickTransferCandidate(outDate, candidates)
+		if in == nil {
+			if len(candidates) > 1 {
+				fmt.Printf("[INFO] Transfer match skipped: %s %s has %d candidates\n", out.Date, out.Amount, len(candidates))
+			}
+			continue
+		}
+
+		link := database.TransferLink{
+			OutID:   out.ID,
+			InID:    in.ID,
+			Kind:    transferKind(out, in, ledgerAccounts),
+			Status:  TransferSuggested,
+			DayGap:  abs(dayGap(outDate, in.Date)),
+			Created: time.Now(),
+		}
+		if err := m.DB.Create(&link).Error; err != nil {
+			return count, err
+		}
+		linked[out.ID] = true
+		linked[in.ID] = true
+		count++
+	}
+
+	if count > 0 {
+		fmt.Printf("[INFO] Suggested %d transfer matches\n", count)
+	}
+	return count, nil
+}
+
+// pickTransferCandidate returns the only candidate, or breaks a tie on transfer-like payees
+// and then on the closest date. Returns nil when still ambiguous.
+func pickTransferCandidate(outDate time.Time, candidates []*database.Transaction) *database.Transaction {
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	}
+
+	var keyword []*database.Transaction
+	for _, c := range candidates {
+		if transferPayeeRe.MatchString(c.Payee) || c.Provider == "splitwise_payment" {
+			keyword = append(keyword, c)
+		}
+	}
+	if len(keyword) == 1 {
+		return keyword[0]
+	}
+	if len(keyword) > 1 {
+		candidates = keyword
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return abs(dayGap(outDate, candidates[i].Date)) < abs(dayGap(outDate, candidates[j].Date))
+	})
+	if abs(dayGap(outDate, candidates[0].Date)) < abs(dayGap(outDate, candidates[1].Date)) {
+		return candidates[0]
+	}
+	return nil
+}
+
+func transferKind(out, in *database.Transaction, ledgerAccounts map[string]string) string {
+	if out.Provider == "splitwise_payment" || in.Provider == "splitwise_payment" {
+		return "splitwise_settlement"
+	}
+	if strings.HasPrefix(ledgerAccounts[out.AccountID], "Liabilities") ||
+		strings.HasPrefix(ledgerAccounts[in.AccountID], "Liabilities") {
+		return "card_payment"
+	}
+	return "transfer"
+}
+
+// SetStatus confirms or rejects a suggested link.
+// Confirming marks both legs reviewed since the user has now looked at them.
+func (m *TransferMatcher) SetStatus(id uint, status string) error {
+	if status != TransferConfirmed && status != TransferRejected {
+		return fmt.Errorf("invalid status %q", status)
+	}
+
+	var link database.TransferLink
+	if err := m.DB.First(&link, id).Error; err != nil {
+		return err
+	}
+
+	return m.DB.Transaction(func(tx *gorm.DB) error {
+		link.Status = status
+		if err := tx.Save(&link).Error; err != nil {
+			return err
+		}
+		if status == TransferConfirmed {
+			return tx.Model(&database.Transaction{}).
+				Where("id IN ?", []string{link.OutID, link.InID}).
+				Update("is_reviewed", true).Error
+		}
+		return nil
+	})
+}
+
+// ConfirmedTransfers maps each leg's transaction ID to its confirmed link
+func ConfirmedTransfers(db *gorm.DB) (map[string]database.TransferLink, error) {
+	var links []database.TransferLink
+	if err := db.Where("status = ?", TransferConfirmed).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	byTx := make(map[string]database.TransferLink)
+	for _, l := range links {
+		byTx[l.OutID] = l
+		byTx[l.InID] = l
+	}
+	return byTx, nil
+}
+
+// dayGap is the signed number of days from a to the YYYY-MM-DD date b
+func dayGap(a time.Time, b string) int {
+	t, err := time.Parse("2006-01-02", b)
+	if err != nil {
+		return 1 << 30
+	}
+	return int(t.Sub(a).Hours() / 24)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"testing"
+
+	"expense_tracker/database"
+)
+
+func TestTransferMatcherRun(t *testing.T) {
+	tests := []struct {
+		name string
+		txs  []database.Transaction
+		want map[string]string // OutID -> InID of the suggested links
+		kind string
+	}{
+		{
+			name: "card payment",
+			txs: []database.Transaction{
+				{ID: "o", AccountID: "chk", Date: "2024-03-01", Payee: "CARD AUTOPAY", Amount: -10000},
+				{ID: "i", AccountID: "card", Date: "2024-03-03", Payee: "PAYMENT THANK YOU", Amount: 10000},
+			},
+			want: map[string]string{"o": "i"},
+			kind: "card_payment",
+		},
+		{
+			name: "too far apart",
+			txs: []database.Transaction{
+				{ID: "o", AccountID: "chk", Date: "2024-03-01", Amount: -10000},
+				{ID: "i", AccountID: "sav", Date: "2024-03-10", Amount: 10000},
+			},
+		},
+		{
+			name: "same account",
+			txs: []database.Transaction{
+				{ID: "o", AccountID: "chk", Date: "2024-03-01", Amount: -10000},
+				{ID: "i", AccountID: "chk", Date: "2024-03-01", Amount: 10000},
+			},
+		},
+		{
+			name: "tie broken by payee",
+			txs: []database.Transaction{
+				{ID: "o", AccountID: "chk", Date: "2024-03-01", Payee: "ONLINE XFER", Amount: -5000},
+				{ID: "i1", AccountID: "sav", Date: "2024-03-01", Payee: "INTERNAL TRANSFER", Amount: 5000},
+				{ID: "i2", AccountID: "sav", Date: "2024-03-01", Payee: "REFUND", Amount: 5000},
+			},
+			want: map[string]string{"o": "i1"},
+			kind: "transfer",
+		},
+		{
+			name: "ambiguous",
+			txs: []database.Transaction{
+				{ID: "o", AccountID: "chk", Date: "2024-03-01", Amount: -5000},
+				{ID: "i1", AccountID: "sav", Date: "2024-03-02", Amount: 5000},
+				{ID: "i2", AccountID: "sav", Date: "2024-02-29", Amount: 5000},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			db.Create(&[]database.AccountMap{
+				{ExternalID: "chk", LedgerAccount: "Assets:Checking"},
+				{ExternalID: "sav", LedgerAccount: "Assets:Savings"},
+				{ExternalID: "card", LedgerAccount: "Liabilities:Card"},
+			})
+			for i := range tt.txs {
+				tt.txs[i].Provider = "simplefin"
+				tt.txs[i].Currency = "USD"
+			}
+			db.Create(&tt.txs)
+
+			n, err := NewTransferMatcher(db).Run()
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			var links []database.TransferLink
+			db.Find(&links)
+			if n != len(tt.want) || len(links) != len(tt.want) {
+				t.Fatalf("got %d links (%+v), want %v", n, links, tt.want)
+			}
+			for _, l := range links {
+				if tt.want[l.OutID] != l.InID || l.Status != TransferSuggested || l.Kind != tt.kind {
+					t.Errorf("link %+v, want %v of kind %s", l, tt.want, tt.kind)
+				}
+			}
+		})
+	}
+}
+
+func TestTransferMatcherSkipsRejectedPairs(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&[]database.Transaction{
+		{ID: "o", Provider: "simplefin", AccountID: "chk", Date: "2024-03-01", Amount: -5000, Currency: "USD"},
+		{ID: "i", Provider: "simplefin", AccountID: "sav", Date: "2024-03-01", Amount: 5000, Currency: "USD"},
+	})
+	db.Create(&database.TransferLink{OutID: "o", InID: "i", Status: TransferRejected})
+
+	if n, err := NewTransferMatcher(db).Run(); err != nil || n != 0 {
+		t.Errorf("Run = %d, %v; want no new suggestion for a rejected pair", n, err)
+	}
+}